@@ -0,0 +1,104 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/progressbar"
+	"downardo.at/timetracking/internal/report"
+	"github.com/spf13/viper"
+)
+
+// projectBudget sums up what has been booked on a project in its current
+// budget period.
+func projectBudget(repo domain.Repository, project domain.Project) (report.BudgetUsage, error) {
+	now := time.Now()
+	from, to := report.BudgetPeriodRange(project.BudgetPeriod, now)
+	recordings, err := repo.FindRecordings(domain.RecordingFilter{ProjectTag: project.Tag, From: from, To: to})
+	if err != nil {
+		return report.BudgetUsage{}, err
+	}
+	return report.Budget(project, recordings, now), nil
+}
+
+// budgetBar renders the used share of a budget as a bar, e.g.
+// "|███       |  37%". The bar is full once the budget is used up.
+func budgetBar(share float64) string {
+	percent := int(share*100 + 0.5)
+	bar := progressbar.NewOptions(100,
+		progressbar.OptionSetWriter(io.Discard),
+		progressbar.OptionSetWidth(10),
+		progressbar.OptionSetPredictTime(false),
+		progressbar.OptionSetElapsedTime(false),
+	)
+	bar.Set(min(percent, 100))
+	if percent == 0 {
+		bar.RenderBlank()
+	}
+	// keep the bar only, the renderer's percentage stops at 100
+	rendered := bar.String()
+	if start, end := strings.Index(rendered, "|"), strings.LastIndex(rendered, "|"); start >= 0 && end > start {
+		rendered = rendered[start : end+1]
+	}
+	return fmt.Sprintf("%s %3d%%", rendered, percent)
+}
+
+// budgetString describes the budget usage of a project for project lists,
+// empty for projects without a budget.
+func budgetString(repo domain.Repository, project domain.Project) (string, error) {
+	if !project.HasBudget() {
+		return "", nil
+	}
+	usage, err := projectBudget(repo, project)
+	if err != nil {
+		return "", err
+	}
+	return budgetBar(usage.Share(project)) + " " + budgetPeriodString(project.BudgetPeriod), nil
+}
+
+func budgetPeriodString(period string) string {
+	if period == domain.BudgetTotal {
+		return "total"
+	}
+	return "per " + period
+}
+
+// budgetWarning returns a warning if the project has used more of its
+// budget than the budgetWarning setting allows, in percent.
+func budgetWarning(repo domain.Repository, tag string) (string, error) {
+	project, err := repo.GetProjectByTag(tag)
+	if err != nil || !project.HasBudget() {
+		return "", err
+	}
+	usage, err := projectBudget(repo, *project)
+	if err != nil {
+		return "", err
+	}
+	share := usage.Share(*project)
+	if share*100 < viper.GetFloat64("budgetWarning") {
+		return "", nil
+	}
+	return fmt.Sprintf("Warning: %.0f%% of the budget of %s used (%s)", share*100, project.Tag, budgetPeriodString(project.BudgetPeriod)), nil
+}
+
+// validateBudget accepts an empty budget or a positive number, parsed like
+// rates.
+func validateBudget(str string) error {
+	if budget, err := parseRate(str); err != nil || budget < 0 {
+		return errors.New("please enter a number like 40 or 12.5")
+	}
+	return nil
+}
+
+func validateBudgetPeriod(period string) error {
+	for _, known := range domain.BudgetPeriods {
+		if period == known {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown budget period %q, use week, month, quarter or year", period)
+}
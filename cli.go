@@ -0,0 +1,669 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/report"
+	"downardo.at/timetracking/internal/utils"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/viper"
+)
+
+// errUsage marks errors caused by invalid arguments. They exit with code 2.
+var errUsage = errors.New("invalid usage")
+
+type command struct {
+	name string
+	// usage has one line per sub command
+	usage []string
+	run   func(repo domain.Repository, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{"start", []string{"start [--task (id)] (tag) (name) [#label]..."}, cmdStart},
+		{"stop", []string{"stop"}, cmdStop},
+		{"status", []string{"status"}, cmdStatus},
+		{"report", []string{
+			"report week [--year n] [--week n] [--project (tag)] [--billable yes|no] [--label (label)] [--format table|json|csv]",
+			"report month [--year n] [--month n] [--project (tag)] [--billable yes|no] [--label (label)] [--format table|json|csv]",
+			"report quarter [--year n] [--quarter n] [--project (tag)] [--billable yes|no] [--label (label)] [--format table|json|csv]",
+			"report year [--year n] [--project (tag)] [--billable yes|no] [--label (label)] [--format table|json|csv]",
+			"report --from (date) [--to (date)] [--project (tag)] [--billable yes|no] [--label (label)] [--format table|json|csv]",
+			"report tasks [--project (tag)] [--all] [--format table|json|csv]",
+		}, cmdReport},
+		{"client", []string{
+			"client add --name (name) [--address (text)] [--vat-id (id)] [--rate (amount)] [--currency (code)]",
+			"client list",
+			"client delete (id)",
+		}, cmdClient},
+		{"export", []string{
+			"export csv [--from (date)] [--to (date)] [--project (tag)] [--billable yes|no] [--label (label)] [--columns (list)] [--delimiter (char)] [--duration decimal|hhmm] [--output (file)]",
+			"export json [--output (file)]",
+			"export timew|watson|timeclock|ics [--from (date)] [--to (date)] [--project (tag)] [--billable yes|no] [--label (label)] [--output (file)]",
+		}, cmdExport},
+		{"import", []string{
+			"import csv [--map (field=column,...)] [--delimiter (char)] [--create-projects] [--skip-invalid] [--dry-run] (file)",
+			"import json [--mode merge|replace] [--dry-run] (file)",
+			"import toggl [--map (name=TAG)]... [--skip-invalid] [--dry-run] (file.csv|file.json)",
+			"import timew [--create-projects] [--skip-invalid] [--dry-run] (file.data)",
+			"import watson [--create-projects] [--skip-invalid] [--dry-run] (frames)",
+			"import ics [--from (date)] [--to (date)] [--rule (field:pattern=TAG)]... [--project (tag)] [--all] [--dry-run] (file.ics)",
+		}, cmdImport},
+		{"invoice", []string{
+			"invoice create --from (date) --to (date) [--output-dir (dir)] [--dry-run] (tag)",
+			"invoice list",
+			"invoice show [--format html|md] [--output (file)] (number)",
+		}, cmdInvoice},
+		{"migrate", []string{"migrate status"}, cmdMigrate},
+		{"project", []string{
+			"project add --tag (tag) --name (name) [--type (type)] [--client (id)] [--rate (amount)] [--budget-hours (hours)] [--budget-amount (amount)] [--budget-period week|month|quarter|year] [--status planned|active|on-hold|completed|archived]",
+			"project list [--all] [--status planned|active|on-hold|completed|archived]",
+			"project status (tag) planned|active|on-hold|completed|archived",
+			"project delete [--reassign (tag)] [--archive] (tag)",
+		}, cmdProject},
+		{"type", []string{
+			"type add --name (name) [--billable] [--color red|green|yellow|blue|magenta|cyan]",
+			"type list",
+			"type delete (name)",
+		}, cmdType},
+		{"task", []string{
+			"task add --project (tag) --name (name) [--estimate (duration)]",
+			"task list [--project (tag)] [--all]",
+			"task done (id)",
+			"task delete (id)",
+		}, cmdTask},
+	}
+}
+
+// runCLI runs a single command without the interactive interface and returns
+// the process exit code.
+func runCLI(args []string) int {
+	log.SetFlags(0)
+	log.SetPrefix("timetracking: ")
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		printCommandUsage(os.Stdout)
+		return 0
+	case "version", "--version":
+		fmt.Println(VERSION)
+		return 0
+	}
+
+	initConfig()
+	var repo domain.Repository
+	if args[0] == "migrate" {
+		// show the schema as it is, migrations run on every other command
+		repo = openDatabase()
+	} else {
+		repo = initDatabase()
+	}
+
+	if err := runCommand(repo, args); err != nil {
+		fmt.Fprintln(os.Stderr, "timetracking:", err)
+		if errors.Is(err, errUsage) {
+			return 2
+		}
+		return 1
+	}
+	return 0
+}
+
+func printCommandUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: timetracking [command]")
+	fmt.Fprintln(w, "Without a command the interactive interface is started.")
+	fmt.Fprintln(w, "Commands:")
+	for _, cmd := range commands() {
+		for _, usage := range cmd.usage {
+			fmt.Fprintln(w, "  "+usage)
+		}
+	}
+}
+
+// runCommand dispatches args to the matching command.
+func runCommand(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: no command given", errUsage)
+	}
+	for _, cmd := range commands() {
+		if cmd.name != args[0] {
+			continue
+		}
+		err := cmd.run(repo, args[1:])
+		if errors.Is(err, errUsage) {
+			return fmt.Errorf("%w\nusage: timetracking %s", err, strings.Join(cmd.usage, "\n       timetracking "))
+		}
+		return err
+	}
+	return fmt.Errorf("%w: unknown command %q, see 'timetracking help'", errUsage, args[0])
+}
+
+// parseArgs parses flags that may be mixed with positional arguments and
+// returns the positional ones.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	fs.SetOutput(io.Discard)
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, fmt.Errorf("%w: %v", errUsage, err)
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// splitArgs splits a line typed into the interactive interface like a shell
+// would, keeping quoted strings together.
+func splitArgs(text string) []string {
+	var args []string
+	var current strings.Builder
+	var quote rune
+	inArg := false
+	for _, c := range text {
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(c)
+		case c == '"' || c == '\'':
+			quote = c
+			inArg = true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args
+}
+
+func cmdStart(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("start", flag.ContinueOnError)
+	taskID := fs.Int64("task", 0, "id of the task to book on, its name is the default name")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return fmt.Errorf("%w: tag and name are required", errUsage)
+	}
+	name, labels, err := parseLabels(strings.Join(args[1:], " "))
+	if err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+	if name == "" && *taskID == 0 {
+		return fmt.Errorf("%w: tag and name are required", errUsage)
+	}
+	if *taskID != 0 {
+		if _, err := repo.GetTask(*taskID); errors.Is(err, domain.ErrNotExists) {
+			return fmt.Errorf("task %d not found", *taskID)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	started, stopped, err := startRecording(repo, args[0], name, *taskID, labels)
+	if errors.Is(err, domain.ErrNotExists) {
+		return fmt.Errorf("project %q not found", args[0])
+	} else if errors.Is(err, domain.ErrTaskProject) {
+		return fmt.Errorf("task %d belongs to another project than %s", *taskID, args[0])
+	} else if errors.Is(err, domain.ErrProjectClosed) {
+		return fmt.Errorf("project %s is completed or archived, set it active to start recordings", args[0])
+	} else if errors.Is(err, domain.ErrRunning) {
+		return errors.New("another recording is running, stop it first")
+	} else if err != nil {
+		return err
+	}
+	if stopped != nil {
+		fmt.Printf("Stopped %s - %s (%s)\n", stopped.ProjectTag, stopped.Name, utils.FormatDuration(stopped.Duration()))
+	}
+	fmt.Printf("Started %s - %s\n", started.ProjectTag, started.Name)
+	if warning, err := budgetWarning(repo, started.ProjectTag); err != nil {
+		return err
+	} else if warning != "" {
+		fmt.Fprintln(os.Stderr, warning)
+	}
+	return nil
+}
+
+func cmdStop(repo domain.Repository, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: stop takes no arguments", errUsage)
+	}
+	stopped, err := stopRecording(repo)
+	if errors.Is(err, domain.ErrNotExists) {
+		return errors.New("no recording is running")
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("Stopped %s - %s (%s)\n", stopped.ProjectTag, stopped.Name, utils.FormatDuration(stopped.Duration()))
+	return nil
+}
+
+func cmdStatus(repo domain.Repository, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: status takes no arguments", errUsage)
+	}
+	running, err := repo.GetRunningRecording()
+	if errors.Is(err, domain.ErrNotExists) {
+		fmt.Println("No recording is running")
+		return nil
+	} else if err != nil {
+		return err
+	}
+	fmt.Printf("Running %s - %s since %s (%s)\n", running.ProjectTag, running.Name, running.StartTime.Format(utils.DateTimeLayout), utils.FormatDuration(running.Duration()))
+	return nil
+}
+
+// reportRecording is the JSON representation of a recording in reports.
+type reportRecording struct {
+	ID       int64      `json:"id"`
+	Project  string     `json:"project"`
+	Name     string     `json:"name"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	Hours    float64    `json:"hours"`
+	Billable bool       `json:"billable"`
+	Note     string     `json:"note,omitempty"`
+	Labels   []string   `json:"labels,omitempty"`
+}
+
+func newReportRecording(recording domain.Recording) reportRecording {
+	r := reportRecording{
+		ID:       recording.ID,
+		Project:  recording.ProjectTag,
+		Name:     recording.Name,
+		Start:    recording.StartTime,
+		Hours:    recording.Duration().Hours(),
+		Billable: recording.Billable,
+		Note:     recording.Note,
+		Labels:   recording.Labels,
+	}
+	if !recording.IsRunning() {
+		end := recording.EndTime
+		r.End = &end
+	}
+	return r
+}
+
+func cmdReport(repo domain.Repository, args []string) error {
+	if len(args) > 0 && args[0] == "tasks" {
+		return taskReport(repo, args[1:])
+	}
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	now := time.Now()
+	year := fs.Int("year", 0, "year, ISO year for weeks (default current)")
+	isoYear, week := now.ISOWeek()
+	fs.IntVar(&week, "week", week, "ISO week")
+	month := fs.Int("month", int(now.Month()), "month (1-12)")
+	quarter := fs.Int("quarter", (int(now.Month())-1)/3+1, "quarter (1-4)")
+	filter := filterFlags(fs)
+	format := fs.String("format", "table", "output format: table, json or csv")
+	args, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, args[1])
+	}
+	recordingFilter, err := filter()
+	if err != nil {
+		return err
+	}
+
+	// without a period the range is given by --from and --to
+	period := "range"
+	if len(args) == 1 {
+		period = args[0]
+		if !recordingFilter.From.IsZero() || !recordingFilter.To.IsZero() {
+			return fmt.Errorf("%w: --from and --to can't be combined with a period", errUsage)
+		}
+	}
+	if *year == 0 {
+		*year = now.Year()
+		if period == "week" {
+			*year = isoYear
+		}
+	}
+
+	var start, end time.Time
+	var title string
+	switch period {
+	case "week":
+		if week < 1 || week > 53 {
+			return fmt.Errorf("%w: week has to be between 1 and 53", errUsage)
+		}
+		return weekReport(repo, recordingFilter, *year, week, *format)
+	case "month":
+		if *month < 1 || *month > 12 {
+			return fmt.Errorf("%w: month has to be between 1 and 12", errUsage)
+		}
+		start, end = utils.MonthRange(*year, time.Month(*month))
+		title = start.Format("January 2006")
+	case "quarter":
+		if *quarter < 1 || *quarter > 4 {
+			return fmt.Errorf("%w: quarter has to be between 1 and 4", errUsage)
+		}
+		start, end = utils.QuarterRange(*year, *quarter)
+		title = fmt.Sprintf("Q%d %d", *quarter, *year)
+	case "year":
+		start, end = utils.YearRange(*year)
+		title = strconv.Itoa(*year)
+	case "range":
+		if recordingFilter.From.IsZero() {
+			return fmt.Errorf("%w: a period or --from is required", errUsage)
+		}
+		start = recordingFilter.From
+		end = utils.DayStart(now)
+		if !recordingFilter.To.IsZero() {
+			end = recordingFilter.To.AddDate(0, 0, -1)
+		}
+		if end.Before(start) {
+			return fmt.Errorf("%w: --to is before --from", errUsage)
+		}
+		title = "Report"
+	default:
+		return fmt.Errorf("%w: unknown report %q", errUsage, period)
+	}
+
+	recordingFilter.From = start
+	recordingFilter.To = end.AddDate(0, 0, 1)
+	recordings, err := repo.FindRecordings(recordingFilter)
+	if err != nil {
+		return err
+	}
+	projects, err := repo.AllProjects()
+	if err != nil {
+		return err
+	}
+	clients, err := repo.AllClients()
+	if err != nil {
+		return err
+	}
+	summary := report.Summarize(recordings, projects, clients, viper.GetString("currency"), recordingFilter.From, recordingFilter.To)
+
+	switch *format {
+	case "table":
+		fmt.Printf("%s (%s - %s)\n", title, start.Format("02.01.2006"), end.Format("02.01.2006"))
+		renderSummary(os.Stdout, summary, table.StyleLight)
+		return nil
+	case "json":
+		return writeSummaryJSON(os.Stdout, summary)
+	case "csv":
+		return writeSummaryCSV(os.Stdout, summary)
+	default:
+		return fmt.Errorf("%w: unknown format %q", errUsage, *format)
+	}
+}
+
+// weekReport lists the recordings of an ISO week.
+func weekReport(repo domain.Repository, filter domain.RecordingFilter, year int, week int, format string) error {
+	start, end := utils.WeekRange(year, week)
+	filter.From = start
+	filter.To = end.AddDate(0, 0, 1)
+	recordings, err := repo.FindRecordings(filter)
+	if err != nil {
+		return err
+	}
+
+	switch format {
+	case "table":
+		fmt.Printf("Week %d Year %d (%s - %s)\n", week, year, start.Format("02.01.2006"), end.Format("02.01.2006"))
+		renderWeekTable(os.Stdout, recordings, start, end, table.StyleLight)
+	case "json":
+		report := struct {
+			Year       int               `json:"year"`
+			Week       int               `json:"week"`
+			From       string            `json:"from"`
+			To         string            `json:"to"`
+			Recordings []reportRecording `json:"recordings"`
+			TotalHours float64           `json:"totalHours"`
+		}{Year: year, Week: week, From: start.Format(utils.DateLayout), To: end.Format(utils.DateLayout), Recordings: []reportRecording{}}
+		for _, recording := range recordings {
+			report.Recordings = append(report.Recordings, newReportRecording(recording))
+			report.TotalHours += recording.Duration().Hours()
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(report)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"id", "project", "name", "start", "end", "hours", "billable", "note", "labels"})
+		for _, recording := range recordings {
+			var endTime string
+			if !recording.IsRunning() {
+				endTime = recording.EndTime.Format(time.RFC3339)
+			}
+			w.Write([]string{strconv.FormatInt(recording.ID, 10), recording.ProjectTag, recording.Name, recording.StartTime.Format(time.RFC3339), endTime, strconv.FormatFloat(recording.Duration().Hours(), 'f', 2, 64), strconv.FormatBool(recording.Billable), recording.Note, strings.Join(recording.Labels, " ")})
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		return fmt.Errorf("%w: unknown format %q", errUsage, format)
+	}
+	return nil
+}
+
+func cmdProject(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing project command", errUsage)
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("project add", flag.ContinueOnError)
+		tag := fs.String("tag", "", "project tag")
+		name := fs.String("name", "", "project name")
+		projectType := fs.String("type", "other", "project type")
+		status := fs.String("status", "active", "planned, active, on-hold, completed or archived")
+		rate := fs.Float64("rate", 0, "hourly rate, the client's default rate if not set")
+		clientID := fs.Int64("client", 0, "id of the client owning the project")
+		budgetHours := fs.Float64("budget-hours", 0, "hours budgeted per budget period")
+		budgetAmount := fs.Float64("budget-amount", 0, "billable amount budgeted per budget period")
+		budgetPeriod := fs.String("budget-period", domain.BudgetTotal, "budget period, the whole project if empty")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 || *tag == "" || *name == "" {
+			return fmt.Errorf("%w: --tag and --name are required", errUsage)
+		}
+		if len(*tag) > 10 {
+			return fmt.Errorf("%w: tag is too long", errUsage)
+		}
+		if _, err := repo.GetProjectByTag(*tag); err == nil {
+			return fmt.Errorf("project %q already exists", *tag)
+		}
+		if *rate < 0 {
+			return fmt.Errorf("%w: rate can't be negative", errUsage)
+		}
+		if *budgetHours < 0 || *budgetAmount < 0 {
+			return fmt.Errorf("%w: budget can't be negative", errUsage)
+		}
+		if err := validateBudgetPeriod(*budgetPeriod); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		projectStatus, err := domain.ParseProjectStatus(*status)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if _, err := repo.GetProjectType(*projectType); errors.Is(err, domain.ErrNotExists) {
+			return fmt.Errorf("unknown project type %q, see 'timetracking type list'", *projectType)
+		} else if err != nil {
+			return err
+		}
+		project := domain.Project{Tag: *tag, Name: *name, Type: *projectType, Status: projectStatus, HourlyRate: *rate, ClientID: *clientID, BudgetHours: *budgetHours, BudgetAmount: *budgetAmount, BudgetPeriod: *budgetPeriod}
+		if *clientID != 0 {
+			client, err := repo.GetClient(*clientID)
+			if errors.Is(err, domain.ErrNotExists) {
+				return fmt.Errorf("client %d not found", *clientID)
+			} else if err != nil {
+				return err
+			}
+			if project.HourlyRate == 0 {
+				project.HourlyRate = client.HourlyRate
+			}
+		}
+		if _, err := repo.CreateProject(project); errors.Is(err, domain.ErrDuplicate) {
+			return fmt.Errorf("project %q already exists", *tag)
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("Created project %s\n", *tag)
+	case "list":
+		fs := flag.NewFlagSet("project list", flag.ContinueOnError)
+		all := fs.Bool("all", false, "list projects in any state")
+		status := fs.String("status", "active", "list projects in this state")
+		if _, err := parseArgs(fs, args[1:]); err != nil {
+			return err
+		}
+		projectStatus, err := domain.ParseProjectStatus(*status)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		projects, err := repo.AllProjects()
+		if err != nil {
+			return err
+		}
+		if !*all {
+			projects = projectsWithStatus(projects, projectStatus)
+		}
+		clients, err := clientNames(repo)
+		if err != nil {
+			return err
+		}
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+		types, err := projectTypes(repo)
+		if err != nil {
+			return err
+		}
+		t.AppendHeader(table.Row{"Tag", "Name", "Type", "Client", "Status", "Rate", "Budget"})
+		for _, project := range projects {
+			budget, err := budgetString(repo, project)
+			if err != nil {
+				return err
+			}
+			t.AppendRow(table.Row{project.Tag, project.Name, typeString(types, project.Type), clients[project.ClientID], project.Status.String(), rateString(project.HourlyRate), budget})
+		}
+		t.SetStyle(table.StyleLight)
+		t.Render()
+	case "delete":
+		fs := flag.NewFlagSet("project delete", flag.ContinueOnError)
+		reassign := fs.String("reassign", "", "move the recordings to this project before deleting")
+		archive := fs.Bool("archive", false, "archive the project instead of deleting it")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) != 1 {
+			return fmt.Errorf("%w: tag is required", errUsage)
+		}
+		tag := rest[0]
+		project, err := repo.GetProjectByTag(tag)
+		if errors.Is(err, domain.ErrNotExists) {
+			return fmt.Errorf("project %q not found", tag)
+		} else if err != nil {
+			return err
+		}
+		if *archive {
+			project.Status = domain.StatusArchived
+			if _, err := repo.UpdateProject(tag, *project); err != nil {
+				return err
+			}
+			fmt.Printf("Archived project %s\n", tag)
+			return nil
+		}
+		if *reassign != "" {
+			moved, err := repo.ReassignRecordings(tag, *reassign)
+			if errors.Is(err, domain.ErrNotExists) {
+				return fmt.Errorf("project %q not found", *reassign)
+			} else if err != nil {
+				return err
+			}
+			fmt.Printf("Moved %d recordings to %s\n", moved, *reassign)
+		}
+		if err := repo.DeleteProject(tag); errors.Is(err, domain.ErrProjectInUse) {
+			count, _ := repo.CountRecordingsByProjectTag(tag)
+			if *reassign != "" {
+				return fmt.Errorf("project %q has %d invoiced recordings that can't be moved, use --archive", tag, count)
+			}
+			return fmt.Errorf("project %q has %d recordings, use --reassign (tag) or --archive", tag, count)
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted project %s\n", tag)
+	case "status":
+		if len(args) != 3 {
+			return fmt.Errorf("%w: tag and status are required", errUsage)
+		}
+		tag := args[1]
+		status, err := domain.ParseProjectStatus(args[2])
+		if err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		project, err := repo.GetProjectByTag(tag)
+		if errors.Is(err, domain.ErrNotExists) {
+			return fmt.Errorf("project %q not found", tag)
+		} else if err != nil {
+			return err
+		}
+		previous := project.Status
+		project.Status = status
+		if _, err := repo.UpdateProject(tag, *project); err != nil {
+			return err
+		}
+		fmt.Printf("Project %s is %s now, was %s\n", tag, status, previous)
+	default:
+		return fmt.Errorf("%w: unknown project command %q", errUsage, args[0])
+	}
+	return nil
+}
+
+func cmdMigrate(repo domain.Repository, args []string) error {
+	if len(args) != 1 || args[0] != "status" {
+		return fmt.Errorf("%w: unknown migrate command", errUsage)
+	}
+	status, err := repo.MigrationStatus()
+	if err != nil {
+		return err
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Version", "Description", "Applied"})
+	for _, migration := range status {
+		applied := "pending"
+		if migration.Applied && !migration.AppliedAt.IsZero() {
+			applied = migration.AppliedAt.Format(utils.DateTimeLayout)
+		} else if migration.Applied {
+			applied = "yes"
+		}
+		t.AppendRow(table.Row{migration.Version, migration.Description, applied})
+	}
+	t.SetStyle(table.StyleLight)
+	t.Render()
+	return nil
+}
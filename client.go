@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"downardo.at/timetracking/internal/domain"
+	"github.com/charmbracelet/huh"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// projectClient returns the client of a project, nil if it has none.
+func projectClient(repo domain.Repository, project domain.Project) (*domain.Client, error) {
+	if project.ClientID == 0 {
+		return nil, nil
+	}
+	return repo.GetClient(project.ClientID)
+}
+
+// clientNames maps the client IDs to their names for project lists.
+func clientNames(repo domain.Repository) (map[int64]string, error) {
+	clients, err := repo.AllClients()
+	if err != nil {
+		return nil, err
+	}
+	names := map[int64]string{}
+	for _, client := range clients {
+		names[client.ID] = client.Name
+	}
+	return names, nil
+}
+
+// clientOptions lists the clients for selection, starting with "None".
+func clientOptions(repo domain.Repository) []huh.Option[int64] {
+	clients, err := repo.AllClients()
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := []huh.Option[int64]{huh.NewOption("None", int64(0))}
+	for _, client := range clients {
+		options = append(options, huh.NewOption(client.Name, client.ID))
+	}
+	return options
+}
+
+// validateCurrency accepts an empty currency or a three letter code like EUR.
+func validateCurrency(str string) error {
+	if str == "" {
+		return nil
+	}
+	if len(str) != 3 || strings.ToUpper(str) != str || strings.ContainsAny(str, "0123456789 ") {
+		return errors.New("please enter a currency code like EUR")
+	}
+	return nil
+}
+
+// validateClientName checks that a name is set and not used by another
+// client than the one with the given ID.
+func validateClientName(repo domain.Repository, id int64, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("please enter a name")
+	}
+	clients, err := repo.AllClients()
+	if err != nil {
+		return err
+	}
+	for _, client := range clients {
+		if client.Name == name && client.ID != id {
+			return errors.New("client already exists")
+		}
+	}
+	return nil
+}
+
+func printClientList(repo domain.Repository) {
+	clearTerminal()
+
+	clients, err := repo.AllClients()
+	if err != nil {
+		log.Fatal(err)
+	}
+	Notice("Client List")
+	renderClients(clients, table.StyleDouble)
+	Info("Available commands: [new, edit (id), delete (id), exit] [id]")
+	InputPrint()
+}
+
+func renderClients(clients []domain.Client, style table.Style) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"ID", "Name", "Address", "VAT ID", "Rate", "Currency"})
+	for _, client := range clients {
+		t.AppendRow(table.Row{client.ID, client.Name, strings.ReplaceAll(client.Address, "\n", ", "), client.VATID, rateString(client.HourlyRate), client.Currency})
+	}
+	t.SetStyle(style)
+	t.Render()
+}
+
+// parseClientID reads the client ID argument of a menu command.
+func parseClientID(repo domain.Repository, text string) (*domain.Client, error) {
+	args := strings.Split(text, " ")
+	if len(args) < 2 || args[1] == "" {
+		return nil, errors.New("please enter an id")
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("id has to be a number")
+	}
+	client, err := repo.GetClient(id)
+	if err != nil {
+		return nil, errors.New("client not found")
+	}
+	return client, nil
+}
+
+func clientMenu(repo domain.Repository) {
+	clearTerminal()
+	for {
+		printClientList(repo)
+		text, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		// convert CRLF to LF
+		// for Windows
+		text = strings.Replace(text, "\r\n", "", -1)
+		// for Linux
+		text = strings.Replace(text, "\n", "", -1)
+		if text == "new" {
+			clearTerminal()
+			addClientForm(repo)
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "edit") {
+			client, err := parseClientID(repo, text)
+			if err != nil {
+				Info(err)
+			} else {
+				clearTerminal()
+				editClientForm(repo, client)
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "delete") {
+			client, err := parseClientID(repo, text)
+			if err != nil {
+				Info(err)
+			} else {
+				clearTerminal()
+				deleteClientForm(repo, client)
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "exit") {
+			break
+		} else {
+			Info("Invalid command")
+			pressEnterToContinue()
+		}
+	}
+}
+
+// clientForm asks for the details of a client, prefilled with the given
+// ones. It returns false if the user did not confirm.
+func clientForm(repo domain.Repository, client *domain.Client, title string) bool {
+	rate := rateString(client.HourlyRate)
+	confirm := false
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title(title),
+			huh.NewInput().
+				Title("Name").
+				CharLimit(50).
+				Value(&client.Name).
+				Validate(func(str string) error {
+					return validateClientName(repo, client.ID, str)
+				}),
+			huh.NewText().
+				Title("Address").
+				Value(&client.Address),
+			huh.NewInput().
+				Title("VAT ID").
+				CharLimit(20).
+				Value(&client.VATID),
+			huh.NewInput().
+				Title("Default hourly rate").
+				Description("Used for new projects of the client").
+				Value(&rate).
+				Validate(validateRate),
+			huh.NewInput().
+				Title("Currency").
+				Description("Empty for the configured currency").
+				CharLimit(3).
+				Value(&client.Currency).
+				Validate(validateCurrency),
+			huh.NewConfirm().
+				Title("Save client?").
+				Affirmative("Yes!").
+				Negative("No.").
+				Value(&confirm),
+		),
+	)
+	if err := form.Run(); err != nil {
+		log.Fatal(err)
+	}
+	client.HourlyRate, _ = parseRate(rate)
+	return confirm
+}
+
+func addClientForm(repo domain.Repository) {
+	client := domain.Client{}
+	if !clientForm(repo, &client, "New client") {
+		Info("Client creation canceled")
+		return
+	}
+	if _, err := repo.CreateClient(client); err != nil {
+		log.Fatal(err)
+	}
+	Info("Client created successfully!")
+}
+
+func editClientForm(repo domain.Repository, client *domain.Client) {
+	if !clientForm(repo, client, fmt.Sprintf("Edit client %d", client.ID)) {
+		Info("Client update canceled")
+		return
+	}
+	if _, err := repo.UpdateClient(client.ID, *client); err != nil {
+		log.Fatal(err)
+	}
+	Info("Client updated successfully!")
+}
+
+func deleteClientForm(repo domain.Repository, client *domain.Client) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title("Delete client '" + client.Name + "'?").
+		Affirmative("Yes!").
+		Negative("No.").
+		Value(&confirm).
+		Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Client deletion canceled")
+		return
+	}
+	if err := repo.DeleteClient(client.ID); errors.Is(err, domain.ErrClientInUse) {
+		Info("The client still has projects, assign them to another client first")
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	Info("Client deleted successfully!")
+}
+
+func cmdClient(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing client command", errUsage)
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("client add", flag.ContinueOnError)
+		name := fs.String("name", "", "client name")
+		address := fs.String("address", "", "postal address, lines separated by \\n")
+		vatID := fs.String("vat-id", "", "VAT identification number")
+		rate := fs.Float64("rate", 0, "default hourly rate of new projects")
+		currency := fs.String("currency", "", "invoice currency, the configured one if empty")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 || *name == "" {
+			return fmt.Errorf("%w: --name is required", errUsage)
+		}
+		if err := validateClientName(repo, 0, *name); err != nil {
+			return err
+		}
+		if *rate < 0 {
+			return fmt.Errorf("%w: rate can't be negative", errUsage)
+		}
+		if err := validateCurrency(*currency); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		client, err := repo.CreateClient(domain.Client{
+			Name:       *name,
+			Address:    strings.ReplaceAll(*address, `\n`, "\n"),
+			VATID:      *vatID,
+			HourlyRate: *rate,
+			Currency:   *currency,
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created client %d\n", client.ID)
+	case "list":
+		if len(args) > 1 {
+			return fmt.Errorf("%w: unexpected argument %q", errUsage, args[1])
+		}
+		clients, err := repo.AllClients()
+		if err != nil {
+			return err
+		}
+		renderClients(clients, table.StyleLight)
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: client id is required", errUsage)
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid client id %q", errUsage, args[1])
+		}
+		if err := repo.DeleteClient(id); errors.Is(err, domain.ErrClientInUse) {
+			return fmt.Errorf("client %d still has projects", id)
+		} else if errors.Is(err, domain.ErrDeleteFailed) {
+			return fmt.Errorf("client %d not found", id)
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted client %d\n", id)
+	default:
+		return fmt.Errorf("%w: unknown client command %q", errUsage, args[0])
+	}
+	return nil
+}
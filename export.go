@@ -0,0 +1,248 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf8"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/exchange"
+	"downardo.at/timetracking/internal/utils"
+)
+
+// filterFlags registers the flags selecting recordings shared by the export
+// and report commands. The returned function builds the filter after parsing.
+func filterFlags(fs *flag.FlagSet) func() (domain.RecordingFilter, error) {
+	from := fs.String("from", "", "first day ("+utils.DateLayout+")")
+	to := fs.String("to", "", "last day ("+utils.DateLayout+")")
+	project := fs.String("project", "", "project tag")
+	billable := fs.String("billable", "", "yes or no")
+	label := fs.String("label", "", "only recordings with this label")
+
+	return func() (domain.RecordingFilter, error) {
+		filter := domain.RecordingFilter{ProjectTag: *project}
+		if *label != "" {
+			normalized, err := normalizeLabel(*label)
+			if err != nil {
+				return filter, fmt.Errorf("%w: %v", errUsage, err)
+			}
+			filter.Label = normalized
+		}
+		if *from != "" {
+			date, err := utils.ParseDate(*from)
+			if err != nil {
+				return filter, fmt.Errorf("%w: invalid --from date %q", errUsage, *from)
+			}
+			filter.From = date
+		}
+		if *to != "" {
+			date, err := utils.ParseDate(*to)
+			if err != nil {
+				return filter, fmt.Errorf("%w: invalid --to date %q", errUsage, *to)
+			}
+			filter.To = date.AddDate(0, 0, 1)
+		}
+		switch *billable {
+		case "":
+		case "yes", "true":
+			b := true
+			filter.Billable = &b
+		case "no", "false":
+			b := false
+			filter.Billable = &b
+		default:
+			return filter, fmt.Errorf("%w: --billable has to be yes or no", errUsage)
+		}
+		return filter, nil
+	}
+}
+
+// exportRows returns the stopped recordings matching the filter joined with
+// their projects.
+func exportRows(repo domain.Repository, filter domain.RecordingFilter) ([]exchange.Row, error) {
+	recordings, err := repo.FindRecordings(filter)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := repo.AllProjects()
+	if err != nil {
+		return nil, err
+	}
+
+	var stopped []domain.Recording
+	for _, recording := range recordings {
+		if !recording.IsRunning() {
+			stopped = append(stopped, recording)
+		}
+	}
+	return exchange.Join(stopped, projects), nil
+}
+
+// createOutput opens the file given by --output, or stdout if it is empty.
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" || path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+func cmdExport(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing export format", errUsage)
+	}
+	switch args[0] {
+	case "csv":
+		return exportCSV(repo, args[1:])
+	case "json":
+		return exportJSON(repo, args[1:])
+	case "timew":
+		return exportRowsAs(repo, "export timew", args[1:], exchange.WriteTimewarrior)
+	case "watson":
+		return exportRowsAs(repo, "export watson", args[1:], exchange.WriteWatson)
+	case "timeclock":
+		return exportRowsAs(repo, "export timeclock", args[1:], exchange.WriteTimeclock)
+	case "ics":
+		return exportRowsAs(repo, "export ics", args[1:], exchange.WriteICS)
+	default:
+		return fmt.Errorf("%w: unknown export format %q", errUsage, args[0])
+	}
+}
+
+func exportCSV(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("export csv", flag.ContinueOnError)
+	filter := filterFlags(fs)
+	columns := fs.String("columns", strings.Join(exchange.CSVColumns, ","), "comma separated columns")
+	delimiter := fs.String("delimiter", ",", "field delimiter, \"tab\" for tabs")
+	duration := fs.String("duration", exchange.DurationDecimal, "duration format: decimal or hhmm")
+	output := fs.String("output", "", "output file, stdout if empty")
+	if rest, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, rest[0])
+	}
+
+	recordingFilter, err := filter()
+	if err != nil {
+		return err
+	}
+	if *delimiter == "tab" {
+		*delimiter = "\t"
+	}
+	if utf8.RuneCountInString(*delimiter) != 1 {
+		return fmt.Errorf("%w: delimiter has to be a single character", errUsage)
+	}
+	opts := exchange.CSVOptions{
+		Columns:  strings.Split(*columns, ","),
+		Duration: *duration,
+	}
+	opts.Delimiter, _ = utf8.DecodeRuneInString(*delimiter)
+	if err := opts.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	rows, err := exportRows(repo, recordingFilter)
+	if err != nil {
+		return err
+	}
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if err := exchange.WriteCSV(out, rows, opts); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// loadDataset reads all stored data for a backup.
+func loadDataset(repo domain.Repository) (domain.Dataset, error) {
+	var data domain.Dataset
+	var err error
+	if data.ProjectTypes, err = repo.AllProjectTypes(); err != nil {
+		return data, err
+	}
+	if data.Clients, err = repo.AllClients(); err != nil {
+		return data, err
+	}
+	if data.Projects, err = repo.AllProjects(); err != nil {
+		return data, err
+	}
+	if data.Tasks, err = repo.AllTasks(); err != nil {
+		return data, err
+	}
+	if data.Recordings, err = repo.AllRecordings(); err != nil {
+		return data, err
+	}
+	if data.Invoices, err = repo.AllInvoices(); err != nil {
+		return data, err
+	}
+	return data, nil
+}
+
+// exportJSON writes a backup of all projects and recordings.
+func exportJSON(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("export json", flag.ContinueOnError)
+	output := fs.String("output", "", "output file, stdout if empty")
+	if rest, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, rest[0])
+	}
+
+	data, err := loadDataset(repo)
+	if err != nil {
+		return err
+	}
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if err := exchange.WriteBackup(out, exchange.NewBackup(data)); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// exportRowsAs writes the filtered recordings with a writer that has no
+// options of its own.
+func exportRowsAs(repo domain.Repository, name string, args []string, write func(io.Writer, []exchange.Row) error) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	filter := filterFlags(fs)
+	output := fs.String("output", "", "output file, stdout if empty")
+	if rest, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, rest[0])
+	}
+
+	recordingFilter, err := filter()
+	if err != nil {
+		return err
+	}
+	rows, err := exportRows(repo, recordingFilter)
+	if err != nil {
+		return err
+	}
+	out, err := createOutput(*output)
+	if err != nil {
+		return err
+	}
+	if err := write(out, rows); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,644 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/exchange"
+	"downardo.at/timetracking/internal/progressbar"
+	"downardo.at/timetracking/internal/utils"
+	"github.com/charmbracelet/huh"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+func cmdImport(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing import format", errUsage)
+	}
+	switch args[0] {
+	case "csv":
+		return importCSV(repo, args[1:])
+	case "json":
+		return importJSON(repo, args[1:])
+	case "toggl":
+		return importToggl(repo, args[1:])
+	case "timew":
+		return importFile(repo, "import timew", args[1:], exchange.ReadTimewarrior)
+	case "watson":
+		return importFile(repo, "import watson", args[1:], exchange.ReadWatson)
+	case "ics":
+		return importICS(repo, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown import format %q", errUsage, args[0])
+	}
+}
+
+// importFlags are the flags shared by all importers.
+type importFlags struct {
+	createProjects *bool
+	skipInvalid    *bool
+	dryRun         *bool
+}
+
+func newImportFlags(fs *flag.FlagSet) importFlags {
+	return importFlags{
+		createProjects: fs.Bool("create-projects", false, "create missing projects instead of rejecting their rows"),
+		skipInvalid:    fs.Bool("skip-invalid", false, "import the valid rows even if some are invalid"),
+		dryRun:         fs.Bool("dry-run", false, "only show what would be imported"),
+	}
+}
+
+func importCSV(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("import csv", flag.ContinueOnError)
+	mapping := fs.String("map", "", "field=column pairs separated by commas, e.g. tag=Project,start=Begin")
+	delimiter := fs.String("delimiter", ",", "field delimiter, \"tab\" for tabs")
+	flags := newImportFlags(fs)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%w: file is required", errUsage)
+	}
+
+	opts := exchange.CSVImportOptions{Mapping: map[string]string{}}
+	if *mapping != "" {
+		for _, pair := range strings.Split(*mapping, ",") {
+			field, column, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("%w: invalid mapping %q, expected field=column", errUsage, pair)
+			}
+			opts.Mapping[strings.TrimSpace(field)] = strings.TrimSpace(column)
+		}
+	}
+	if *delimiter == "tab" {
+		*delimiter = "\t"
+	}
+	if utf8.RuneCountInString(*delimiter) != 1 {
+		return fmt.Errorf("%w: delimiter has to be a single character", errUsage)
+	}
+	opts.Delimiter, _ = utf8.DecodeRuneInString(*delimiter)
+
+	file, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := exchange.ReadCSV(file, opts)
+	if err != nil {
+		return err
+	}
+	return runImport(repo, rows, flags)
+}
+
+// importFile imports a file with a reader that has no options of its own.
+func importFile(repo domain.Repository, name string, args []string, read func(io.Reader) ([]exchange.ImportRow, error)) error {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags := newImportFlags(fs)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%w: file is required", errUsage)
+	}
+
+	file, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rows, err := read(file)
+	if err != nil {
+		return err
+	}
+	return runImport(repo, rows, flags)
+}
+
+// runImport checks the rows, prints a summary and, unless it is a dry run,
+// stores the new recordings in one transaction.
+func runImport(repo domain.Repository, rows []exchange.ImportRow, flags importFlags) error {
+	plan, err := exchange.Plan(repo, rows, *flags.createProjects)
+	if err != nil {
+		return err
+	}
+	printImportSummary(plan, *flags.dryRun)
+	if *flags.dryRun {
+		return nil
+	}
+
+	if invalid := plan.Count(exchange.StatusInvalid); invalid > 0 && !*flags.skipInvalid {
+		return fmt.Errorf("%d invalid rows, nothing imported; fix them or use --skip-invalid", invalid)
+	}
+	recordings := plan.Recordings()
+	if len(recordings) == 0 {
+		fmt.Println("Nothing to import")
+		return nil
+	}
+
+	bar := progressbar.Default(int64(len(recordings)), "Importing")
+	if err := repo.Import(plan.NewProjects, recordings, func() { bar.Add(1) }); err != nil {
+		bar.Exit()
+		return err
+	}
+	fmt.Printf("Imported %d recordings, created %d projects\n", len(recordings), len(plan.NewProjects))
+	return nil
+}
+
+// printImportSummary lists every row on a dry run, otherwise only the rows
+// that are not imported.
+func printImportSummary(plan *exchange.ImportPlan, all bool) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Line", "Tag", "Start", "End", "Duration", "Name", "Status"})
+	for _, row := range plan.Rows {
+		if !all && row.Status == exchange.StatusNew {
+			continue
+		}
+		status := row.Status
+		if row.Err != nil {
+			status += ": " + row.Err.Error()
+		}
+		var start, end, duration string
+		if !row.StartTime.IsZero() {
+			start = row.StartTime.Format(utils.DateTimeLayout)
+		}
+		if !row.EndTime.IsZero() {
+			end = row.EndTime.Format(utils.DateTimeLayout)
+			duration = utils.FormatDuration(row.Duration())
+		}
+		t.AppendRow(table.Row{row.Line, row.ProjectTag, start, end, duration, row.Name, status})
+	}
+	t.AppendFooter(table.Row{"", "", "New", plan.Count(exchange.StatusNew), "Duplicate", plan.Count(exchange.StatusDuplicate)})
+	t.AppendFooter(table.Row{"", "", "Invalid", plan.Count(exchange.StatusInvalid), "New projects", len(plan.NewProjects)})
+	t.SetStyle(table.StyleLight)
+	t.Render()
+}
+
+// importJSON restores a backup written by "export json".
+func importJSON(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("import json", flag.ContinueOnError)
+	mode := fs.String("mode", "merge", "merge: add to the existing data, replace: delete the existing data first")
+	dryRun := fs.Bool("dry-run", false, "only show what would be imported")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%w: file is required", errUsage)
+	}
+	if *mode != "merge" && *mode != "replace" {
+		return fmt.Errorf("%w: mode has to be merge or replace", errUsage)
+	}
+
+	file, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	backup, err := exchange.ReadBackup(file)
+	if err != nil {
+		return err
+	}
+	plan, err := exchange.PlanRestore(repo, backup, *mode == "replace")
+	if err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"", "Types", "Clients", "Projects", "Tasks", "Recordings", "Invoices"})
+	t.AppendRow(table.Row{"In backup", len(backup.DomainProjectTypes()), len(backup.Clients), len(backup.Projects), len(backup.Tasks), len(backup.Recordings), len(backup.Invoices)})
+	t.AppendRow(table.Row{"To store", len(plan.ProjectTypes), len(plan.Clients), len(plan.Projects), len(plan.Tasks), len(plan.Recordings), len(plan.Invoices)})
+	t.AppendRow(table.Row{"Already existing", plan.SkippedProjectTypes, plan.SkippedClients, plan.SkippedProjects, plan.SkippedTasks, plan.SkippedRecordings, plan.SkippedInvoices})
+	t.AppendRow(table.Row{"New id", "", "", "", "", plan.Renumbered, ""})
+	t.SetStyle(table.StyleLight)
+	t.Render()
+	if *dryRun {
+		return nil
+	}
+
+	if err := repo.Restore(plan.Dataset, *mode == "replace"); err != nil {
+		return err
+	}
+	if *mode == "replace" {
+		fmt.Println("Replaced all data with the backup")
+	} else {
+		fmt.Println("Merged the backup into the existing data")
+	}
+	return nil
+}
+
+// mappingFlag collects repeated --map name=TAG flags.
+type mappingFlag map[string]string
+
+func (m mappingFlag) String() string {
+	return ""
+}
+
+func (m mappingFlag) Set(value string) error {
+	name, tag, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("invalid mapping %q, expected name=TAG", value)
+	}
+	m[strings.TrimSpace(name)] = strings.TrimSpace(tag)
+	return nil
+}
+
+// importToggl imports a Toggl Track detailed report exported as CSV or JSON.
+// Toggl projects without a --map entry are mapped interactively.
+func importToggl(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("import toggl", flag.ContinueOnError)
+	mapping := mappingFlag{}
+	fs.Var(mapping, "map", "map a Toggl project (\"Client / Project\" if it has a client) to a tag, repeatable")
+	flags := newImportFlags(fs)
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%w: file is required", errUsage)
+	}
+
+	file, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var entries []exchange.TogglEntry
+	if strings.EqualFold(filepath.Ext(rest[0]), ".json") {
+		entries, err = exchange.ReadTogglJSON(file)
+	} else {
+		entries, err = exchange.ReadTogglCSV(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	var unmapped []exchange.TogglEntry
+	for _, entry := range entries {
+		if _, ok := mapping[entry.Key()]; !ok {
+			mapping[entry.Key()] = ""
+			unmapped = append(unmapped, entry)
+		}
+	}
+	if len(unmapped) > 0 {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			var keys []string
+			for _, entry := range unmapped {
+				keys = append(keys, entry.Key())
+			}
+			return fmt.Errorf("%w: no --map for %s", errUsage, strings.Join(keys, ", "))
+		}
+		for _, entry := range unmapped {
+			tag, err := togglMappingForm(repo, entry)
+			if err != nil {
+				return err
+			}
+			mapping[entry.Key()] = tag
+		}
+	}
+
+	// every tag comes from the mapping, so missing projects are wanted
+	*flags.createProjects = true
+	return runImport(repo, exchange.TogglRows(entries, mapping), flags)
+}
+
+// togglMappingForm asks which project the Toggl project of the entry belongs
+// to, either an existing one or a new one.
+func togglMappingForm(repo domain.Repository, entry exchange.TogglEntry) (string, error) {
+	projects, err := pickableProjects(repo)
+	if err != nil {
+		return "", err
+	}
+	options := []huh.Option[string]{huh.NewOption("New project", "")}
+	for _, project := range projects {
+		option := huh.NewOption(project.Tag+" - "+project.Name, project.Tag)
+		if strings.EqualFold(project.Name, entry.Project) {
+			option = option.Selected(true)
+		}
+		options = append(options, option)
+	}
+
+	var tag string
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Project for Toggl project \"" + entry.Key() + "\"").
+				Options(options...).
+				Value(&tag),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+	if tag != "" {
+		return tag, nil
+	}
+
+	tag = togglTag(entry.Project)
+	form = huh.NewForm(
+		huh.NewGroup(
+			huh.NewInput().
+				Title("Tag of the new project").
+				CharLimit(10).
+				Value(&tag).
+				Validate(func(str string) error {
+					if str == "" {
+						return errors.New("please enter a tag.")
+					}
+					if _, err := repo.GetProjectByTag(str); err == nil {
+						return errors.New("tag is already used.")
+					}
+					return nil
+				}),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return "", err
+	}
+	return tag, nil
+}
+
+// togglTag suggests a tag for a Toggl project name.
+func togglTag(name string) string {
+	tag := []rune(strings.ToUpper(strings.Join(strings.Fields(name), "")))
+	if len(tag) > 10 {
+		tag = tag[:10]
+	}
+	return string(tag)
+}
+
+// icsRulesFlag collects repeated --rule summary:REGEX=TAG and
+// --rule organizer:ADDRESS=TAG flags.
+type icsRulesFlag []exchange.ICSRule
+
+func (r *icsRulesFlag) String() string {
+	return ""
+}
+
+func (r *icsRulesFlag) Set(value string) error {
+	i := strings.LastIndex(value, "=")
+	if i < 0 {
+		return fmt.Errorf("invalid rule %q, expected summary:REGEX=TAG or organizer:ADDRESS=TAG", value)
+	}
+	rule, err := newICSRule(value[:i], value[i+1:])
+	if err != nil {
+		return err
+	}
+	*r = append(*r, rule)
+	return nil
+}
+
+func newICSRule(criterion, tag string) (exchange.ICSRule, error) {
+	rule := exchange.ICSRule{Tag: strings.TrimSpace(tag)}
+	field, pattern, _ := strings.Cut(criterion, ":")
+	switch field {
+	case "summary":
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return rule, fmt.Errorf("invalid summary pattern: %w", err)
+		}
+		rule.Summary = re
+	case "organizer":
+		rule.Organizer = pattern
+	default:
+		return rule, fmt.Errorf("unknown rule field %q, expected summary or organizer", field)
+	}
+	return rule, nil
+}
+
+// configICSRules reads the icsRules setting, a list of entries with a tag
+// and a summary pattern or an organizer.
+func configICSRules() ([]exchange.ICSRule, error) {
+	var configs []struct {
+		Summary   string
+		Organizer string
+		Tag       string
+	}
+	if err := viper.UnmarshalKey("icsRules", &configs); err != nil {
+		return nil, fmt.Errorf("invalid icsRules setting: %w", err)
+	}
+	var rules []exchange.ICSRule
+	for _, config := range configs {
+		rule := exchange.ICSRule{Organizer: config.Organizer, Tag: config.Tag}
+		if config.Summary != "" {
+			re, err := regexp.Compile(config.Summary)
+			if err != nil {
+				return nil, fmt.Errorf("invalid icsRules pattern %q: %w", config.Summary, err)
+			}
+			rule.Summary = re
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// icsCandidate is an event of the import range and what happens to it.
+type icsCandidate struct {
+	event  exchange.ICSEvent
+	tag    string
+	status string
+}
+
+// importICS creates recordings from calendar events. Events are mapped to
+// projects by the --rule flags, then the icsRules setting, then --project.
+// Events overlapping existing recordings are skipped.
+func importICS(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("import ics", flag.ContinueOnError)
+	from := fs.String("from", "", "first day ("+utils.DateLayout+"), default start of this week")
+	to := fs.String("to", "", "last day ("+utils.DateLayout+"), default end of this week")
+	var rules icsRulesFlag
+	fs.Var(&rules, "rule", "summary:REGEX=TAG or organizer:ADDRESS=TAG, repeatable")
+	project := fs.String("project", "", "tag for events no rule matches")
+	all := fs.Bool("all", false, "import all events without asking")
+	dryRun := fs.Bool("dry-run", false, "only show what would be imported")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%w: file is required", errUsage)
+	}
+
+	year, week := time.Now().ISOWeek()
+	start, end := utils.WeekRange(year, week)
+	end = end.AddDate(0, 0, 1)
+	if *from != "" {
+		if start, err = utils.ParseDate(*from); err != nil {
+			return fmt.Errorf("%w: invalid --from date %q", errUsage, *from)
+		}
+	}
+	if *to != "" {
+		date, err := utils.ParseDate(*to)
+		if err != nil {
+			return fmt.Errorf("%w: invalid --to date %q", errUsage, *to)
+		}
+		end = date.AddDate(0, 0, 1)
+	}
+
+	configRules, err := configICSRules()
+	if err != nil {
+		return err
+	}
+	rules = append(rules, configRules...)
+	if *project != "" {
+		rules = append(rules, exchange.ICSRule{Tag: *project})
+	}
+
+	file, err := os.Open(rest[0])
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	events, err := exchange.ReadICS(file)
+	if err != nil {
+		return err
+	}
+
+	var occurrences []exchange.ICSEvent
+	for _, event := range events {
+		expanded, err := event.Occurrences(start, end)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "timetracking: skipped %q: %v\n", event.Summary, err)
+			continue
+		}
+		occurrences = append(occurrences, expanded...)
+	}
+
+	var candidates []icsCandidate
+	for _, event := range occurrences {
+		if event.AllDay {
+			continue
+		}
+		candidate := icsCandidate{event: event, status: exchange.StatusNew}
+		tag, ok := exchange.MatchICSRules(event, rules)
+		switch {
+		case !ok:
+			candidate.status = "no rule matches"
+		case !event.End.After(event.Start):
+			candidate.status = "no duration"
+		default:
+			candidate.tag = tag
+			if _, err := repo.GetProjectByTag(tag); err != nil {
+				candidate.status = fmt.Sprintf("project %q does not exist", tag)
+			} else if overlapping, err := repo.GetRecordingsByDateRange(event.Start, event.End); err != nil {
+				return err
+			} else if len(overlapping) > 0 {
+				candidate.status = "overlaps a recording"
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].event.Start.Before(candidates[j].event.Start)
+	})
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Start", "End", "Summary", "Tag", "Status"})
+	var importable []icsCandidate
+	for _, candidate := range candidates {
+		t.AppendRow(table.Row{candidate.event.Start.Format(utils.DateTimeLayout), candidate.event.End.Format(utils.DateTimeLayout), candidate.event.Summary, candidate.tag, candidate.status})
+		if candidate.status == exchange.StatusNew {
+			importable = append(importable, candidate)
+		}
+	}
+	t.SetStyle(table.StyleLight)
+	t.Render()
+	if *dryRun {
+		return nil
+	}
+	if len(importable) == 0 {
+		fmt.Println("Nothing to import")
+		return nil
+	}
+
+	if !*all {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return fmt.Errorf("%w: use --all to import without asking", errUsage)
+		}
+		if importable, err = icsEventsForm(importable); err != nil {
+			return err
+		}
+	}
+
+	created := 0
+	for _, candidate := range importable {
+		// events may overlap each other
+		overlapping, err := repo.GetRecordingsByDateRange(candidate.event.Start, candidate.event.End)
+		if err != nil {
+			return err
+		}
+		if len(overlapping) > 0 {
+			fmt.Printf("Skipped %q, it overlaps a recording\n", candidate.event.Summary)
+			continue
+		}
+		project, err := repo.GetProjectByTag(candidate.tag)
+		if err != nil {
+			return err
+		}
+		billable, err := billableDefault(repo, project.Type)
+		if err != nil {
+			return err
+		}
+		_, err = repo.CreateRecording(domain.Recording{
+			ProjectTag: candidate.tag,
+			StartTime:  candidate.event.Start,
+			EndTime:    candidate.event.End,
+			Name:       candidate.event.Summary,
+			Billable:   billable,
+			Note:       candidate.event.Description,
+		})
+		if err != nil {
+			return err
+		}
+		created++
+	}
+	fmt.Printf("Created %d recordings\n", created)
+	return nil
+}
+
+// icsEventsForm lets the user pick the events to import, all selected by
+// default.
+func icsEventsForm(candidates []icsCandidate) ([]icsCandidate, error) {
+	var options []huh.Option[int]
+	for i, candidate := range candidates {
+		label := candidate.event.Start.Format(utils.DateTimeLayout) + "-" + candidate.event.End.Format("15:04") +
+			" " + candidate.tag + " " + candidate.event.Summary
+		options = append(options, huh.NewOption(label, i).Selected(true))
+	}
+	var picked []int
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewMultiSelect[int]().
+				Title("Events to import").
+				Options(options...).
+				Value(&picked),
+		),
+	)
+	if err := form.Run(); err != nil {
+		return nil, err
+	}
+	var selected []icsCandidate
+	for _, i := range picked {
+		selected = append(selected, candidates[i])
+	}
+	return selected, nil
+}
@@ -1,26 +1,150 @@
 package domain
 
-import "time"
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
 
-/**
- * Project status:
- * 0 - active
- * 1 - inactive
-**/
+// ProjectStatus is the lifecycle state of a project. The values are stored,
+// active is 0 for compatibility with earlier versions.
+type ProjectStatus int
+
+const (
+	StatusActive ProjectStatus = iota
+	StatusOnHold
+	StatusPlanned
+	StatusCompleted
+	// StatusArchived hides a project from selections; its recordings stay
+	// in reports.
+	StatusArchived
+)
+
+// ProjectStatuses lists the states in lifecycle order.
+var ProjectStatuses = []ProjectStatus{StatusPlanned, StatusActive, StatusOnHold, StatusCompleted, StatusArchived}
+
+var statusNames = map[ProjectStatus]string{
+	StatusActive:    "active",
+	StatusOnHold:    "on hold",
+	StatusPlanned:   "planned",
+	StatusCompleted: "completed",
+	StatusArchived:  "archived",
+}
+
+// statusTransitions maps each state to the states a project may change to.
+var statusTransitions = map[ProjectStatus][]ProjectStatus{
+	StatusPlanned:   {StatusActive, StatusOnHold, StatusArchived},
+	StatusActive:    {StatusOnHold, StatusCompleted, StatusArchived},
+	StatusOnHold:    {StatusActive, StatusCompleted, StatusArchived},
+	StatusCompleted: {StatusActive, StatusArchived},
+	StatusArchived:  {StatusActive, StatusCompleted},
+}
+
+func (s ProjectStatus) String() string {
+	if name, ok := statusNames[s]; ok {
+		return name
+	}
+	return fmt.Sprintf("unknown (%d)", int(s))
+}
+
+// Valid reports whether s is one of ProjectStatuses.
+func (s ProjectStatus) Valid() bool {
+	_, ok := statusNames[s]
+	return ok
+}
+
+// CanBecome reports whether a project may change from s to the given state.
+// Keeping the state is always allowed.
+func (s ProjectStatus) CanBecome(to ProjectStatus) bool {
+	return s == to || slices.Contains(statusTransitions[s], to)
+}
+
+// Trackable reports whether recordings can be started on projects in state
+// s. Completed and archived projects have to be reactivated first.
+func (s ProjectStatus) Trackable() bool {
+	return s != StatusCompleted && s != StatusArchived
+}
+
+// ParseProjectStatus reads a state by name, like "on hold" or "on-hold".
+func ParseProjectStatus(str string) (ProjectStatus, error) {
+	name := strings.ReplaceAll(strings.ToLower(strings.TrimSpace(str)), "-", " ")
+	for status, statusName := range statusNames {
+		if statusName == name {
+			return status, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown project status %q, use planned, active, on-hold, completed or archived", str)
+}
 
 type Project struct {
 	Tag    string
 	Name   string
 	Type   string
-	Status int
+	Status ProjectStatus
+	// HourlyRate is charged for billable recordings, in the configured
+	// currency.
+	HourlyRate float64
+	// ClientID is the client owning the project, 0 if there is none.
+	ClientID int64
+	// BudgetHours and BudgetAmount limit the time and the billable amount
+	// booked per BudgetPeriod, 0 means no limit.
+	BudgetHours  float64
+	BudgetAmount float64
+	BudgetPeriod string
 }
 
-func (p *Project) StatusString() string {
-	if p.Status == 0 {
-		return "active"
-	} else {
-		return "inactive"
-	}
+// Budget periods of projects. A total budget covers the whole project.
+const (
+	BudgetTotal   = ""
+	BudgetWeek    = "week"
+	BudgetMonth   = "month"
+	BudgetQuarter = "quarter"
+	BudgetYear    = "year"
+)
+
+var BudgetPeriods = []string{BudgetTotal, BudgetWeek, BudgetMonth, BudgetQuarter, BudgetYear}
+
+// HasBudget reports whether the project's hours or amount are limited.
+func (p *Project) HasBudget() bool {
+	return p.BudgetHours > 0 || p.BudgetAmount > 0
+}
+
+// ProjectType classifies projects and carries the defaults of their
+// recordings.
+type ProjectType struct {
+	Name string
+	// BillableDefault makes new recordings of the type's projects billable.
+	// It only sets the default, it doesn't decide what can be invoiced.
+	BillableDefault bool
+	// Color highlights the type in project lists, one of ProjectTypeColors.
+	Color string
+}
+
+// ProjectTypeColors are the colors project types can have, "" being the
+// terminal's default.
+var ProjectTypeColors = []string{"", "red", "green", "yellow", "blue", "magenta", "cyan"}
+
+// DefaultProjectTypes are the project types a new database starts with.
+var DefaultProjectTypes = []ProjectType{
+	{Name: "customer", BillableDefault: true, Color: "green"},
+	{Name: "development", Color: "yellow"},
+	{Name: "internal", Color: "blue"},
+	{Name: "open source", Color: "magenta"},
+	{Name: "other"},
+}
+
+// Client is a customer owning projects and receiving their invoices.
+type Client struct {
+	ID      int64
+	Name    string
+	Address string
+	VATID   string
+	// HourlyRate is the default rate of new projects of the client.
+	HourlyRate float64
+	// Currency is used on the client's invoices instead of the configured
+	// currency if it is set.
+	Currency string
 }
 
 func (r *Recording) StatusString() string {
@@ -45,4 +169,96 @@ type Recording struct {
 	Billable   bool
 	Note       string
 	Status     int
+	// HourlyRate overrides the project's rate if it is not zero.
+	HourlyRate float64
+	// InvoiceNumber is the invoice billing the recording, 0 if it is not
+	// invoiced yet. Invoiced recordings can't be changed.
+	InvoiceNumber int64
+	// TaskID is the task of the project the recording is booked on, 0 if
+	// there is none.
+	TaskID int64
+	// Labels categorize recordings across projects, like "meeting" or
+	// "travel". They are stored lower case and sorted.
+	Labels []string
+}
+
+// IsRunning reports whether the recording has not been stopped yet.
+func (r *Recording) IsRunning() bool {
+	return r.EndTime.IsZero()
+}
+
+// IsInvoiced reports whether the recording has been billed.
+func (r *Recording) IsInvoiced() bool {
+	return r.InvoiceNumber != 0
+}
+
+// Duration returns the tracked time, counting running recordings up to now.
+func (r *Recording) Duration() time.Duration {
+	if r.IsRunning() {
+		return time.Since(r.StartTime)
+	}
+	return r.EndTime.Sub(r.StartTime)
+}
+
+// HasLabel reports whether the recording carries the label.
+func (r *Recording) HasLabel(label string) bool {
+	return slices.Contains(r.Labels, label)
+}
+
+// Rate returns the hourly rate of the recording, its own or the project's.
+func (r *Recording) Rate(project Project) float64 {
+	if r.HourlyRate != 0 {
+		return r.HourlyRate
+	}
+	return project.HourlyRate
+}
+
+// RecordingFilter narrows down the recordings returned by FindRecordings.
+// Zero values disable the respective filter.
+type RecordingFilter struct {
+	ProjectTag string
+	From       time.Time
+	To         time.Time
+	Billable   *bool
+	// Uninvoiced selects only recordings that have not been billed.
+	Uninvoiced bool
+	// Label selects only recordings carrying the label.
+	Label string
+}
+
+// Task is a piece of work within a project. Recordings booked on it add up
+// to its actual effort.
+type Task struct {
+	ID         int64
+	ProjectTag string
+	Name       string
+	// Estimate is the planned effort, 0 if there is no estimate.
+	Estimate time.Duration
+	Done     bool
+}
+
+// Invoice bills the billable recordings of a customer project in a period.
+type Invoice struct {
+	// Number is assigned sequentially when the invoice is created.
+	Number     int64
+	ProjectTag string
+	IssuedAt   time.Time
+	// From and To are the billed period [From, To).
+	From     time.Time
+	To       time.Time
+	Currency string
+	Amount   float64
+	// Lines are the billed recordings as they were billed. Invoices created
+	// before lines were stored have none.
+	Lines []InvoiceLine
+}
+
+// InvoiceLine is a billed recording. Its figures are kept with the invoice,
+// so they don't change with the rates.
+type InvoiceLine struct {
+	Date   time.Time
+	Name   string
+	Hours  float64
+	Rate   float64
+	Amount float64
 }
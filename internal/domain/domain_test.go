@@ -0,0 +1,61 @@
+package domain
+
+import "testing"
+
+func TestParseProjectStatus(t *testing.T) {
+	tests := []struct {
+		str  string
+		want ProjectStatus
+		err  bool
+	}{
+		{str: "planned", want: StatusPlanned},
+		{str: "Active", want: StatusActive},
+		{str: "on hold", want: StatusOnHold},
+		{str: "on-hold", want: StatusOnHold},
+		{str: " completed ", want: StatusCompleted},
+		{str: "archived", want: StatusArchived},
+		{str: "inactive", err: true},
+		{str: "", err: true},
+	}
+	for _, test := range tests {
+		got, err := ParseProjectStatus(test.str)
+		if (err != nil) != test.err || got != test.want {
+			t.Errorf("ParseProjectStatus(%q) = %s, %v", test.str, got, err)
+		}
+	}
+}
+
+func TestProjectStatusCanBecome(t *testing.T) {
+	for _, status := range ProjectStatuses {
+		if !status.CanBecome(status) {
+			t.Errorf("%s can't stay %s", status, status)
+		}
+		if status != StatusArchived && !status.CanBecome(StatusArchived) {
+			t.Errorf("%s can't be archived", status)
+		}
+		if status != StatusPlanned && status.CanBecome(StatusPlanned) {
+			t.Errorf("%s can become planned again", status)
+		}
+	}
+	if StatusCompleted.CanBecome(StatusOnHold) {
+		t.Error("completed projects can be put on hold")
+	}
+	if ProjectStatus(42).Valid() {
+		t.Error("unknown status is valid")
+	}
+}
+
+func TestProjectStatusTrackable(t *testing.T) {
+	want := map[ProjectStatus]bool{
+		StatusPlanned:   true,
+		StatusActive:    true,
+		StatusOnHold:    true,
+		StatusCompleted: false,
+		StatusArchived:  false,
+	}
+	for _, status := range ProjectStatuses {
+		if status.Trackable() != want[status] {
+			t.Errorf("%s trackable is %v, want %v", status, status.Trackable(), want[status])
+		}
+	}
+}
@@ -0,0 +1,795 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryRepository keeps all data in memory. It is meant for
+// tests and trying out the application; nothing is persisted.
+type MemoryRepository struct {
+	lock         sync.Mutex
+	types        map[string]ProjectType
+	clients      map[int64]Client
+	projects     map[string]Project
+	tasks        map[int64]Task
+	recordings   map[int64]Recording
+	invoices     map[int64]Invoice
+	lastID       int64
+	lastClientID int64
+	lastTaskID   int64
+}
+
+var _ Repository = (*MemoryRepository)(nil)
+
+func NewMemoryRepository() *MemoryRepository {
+	r := &MemoryRepository{
+		types:      map[string]ProjectType{},
+		clients:    map[int64]Client{},
+		projects:   map[string]Project{},
+		tasks:      map[int64]Task{},
+		recordings: map[int64]Recording{},
+		invoices:   map[int64]Invoice{},
+	}
+	for _, projectType := range DefaultProjectTypes {
+		r.types[projectType.Name] = projectType
+	}
+	return r
+}
+
+func (r *MemoryRepository) Migrate() error {
+	return nil
+}
+
+// MigrationStatus reports all migrations as applied; the in-memory storage
+// has no schema.
+func (r *MemoryRepository) MigrationStatus() ([]MigrationStatus, error) {
+	var all []MigrationStatus
+	for _, migration := range migrations {
+		all = append(all, MigrationStatus{Migration: migration, Applied: true})
+	}
+	return all, nil
+}
+
+func (r *MemoryRepository) CreateProject(project Project) (*Project, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.projects[project.Tag]; ok {
+		return nil, ErrDuplicate
+	}
+	if !project.Status.Valid() {
+		return nil, fmt.Errorf("invalid project status %d", project.Status)
+	}
+	if _, ok := r.clients[project.ClientID]; project.ClientID != 0 && !ok {
+		return nil, ErrNotExists
+	}
+	r.projects[project.Tag] = project
+	return &project, nil
+}
+
+func (r *MemoryRepository) AllProjects() ([]Project, error) {
+	return r.filterProjects(func(Project) bool { return true }), nil
+}
+
+func (r *MemoryRepository) AllActiveProjects() ([]Project, error) {
+	return r.filterProjects(func(p Project) bool { return p.Status == StatusActive }), nil
+}
+
+func (r *MemoryRepository) filterProjects(keep func(Project) bool) []Project {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var all []Project
+	for _, project := range r.projects {
+		if keep(project) {
+			all = append(all, project)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Tag < all[j].Tag })
+	return all
+}
+
+func (r *MemoryRepository) GetProjectByTag(tag string) (*Project, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	project, ok := r.projects[tag]
+	if !ok {
+		return nil, ErrNotExists
+	}
+	return &project, nil
+}
+
+func (r *MemoryRepository) UpdateProject(tag string, updated Project) (*Project, error) {
+	if tag == "" {
+		return nil, errors.New("invalid project tag")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	existing, ok := r.projects[tag]
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if !existing.Status.CanBecome(updated.Status) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrStatusTransition, existing.Status, updated.Status)
+	}
+	if _, ok := r.clients[updated.ClientID]; updated.ClientID != 0 && !ok {
+		return nil, ErrNotExists
+	}
+	updated.Tag = tag
+	r.projects[tag] = updated
+	return &updated, nil
+}
+
+func (r *MemoryRepository) DeleteProject(tag string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.projects[tag]; !ok {
+		return ErrDeleteFailed
+	}
+	for _, recording := range r.recordings {
+		if recording.ProjectTag == tag {
+			return ErrProjectInUse
+		}
+	}
+	for id, task := range r.tasks {
+		if task.ProjectTag == tag {
+			delete(r.tasks, id)
+		}
+	}
+	delete(r.projects, tag)
+	return nil
+}
+
+func (r *MemoryRepository) CountRecordingsByProjectTag(tag string) (int, error) {
+	recordings, err := r.FindRecordings(RecordingFilter{ProjectTag: tag})
+	return len(recordings), err
+}
+
+func (r *MemoryRepository) ReassignRecordings(fromTag, toTag string) (int64, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.projects[toTag]; !ok {
+		return 0, ErrNotExists
+	}
+	var count int64
+	for id, recording := range r.recordings {
+		if recording.ProjectTag == fromTag && !recording.IsInvoiced() {
+			recording.ProjectTag = toTag
+			recording.TaskID = 0
+			r.recordings[id] = recording
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (r *MemoryRepository) CreateProjectType(projectType ProjectType) (*ProjectType, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.types[projectType.Name]; ok {
+		return nil, ErrDuplicate
+	}
+	r.types[projectType.Name] = projectType
+	return &projectType, nil
+}
+
+func (r *MemoryRepository) AllProjectTypes() ([]ProjectType, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var all []ProjectType
+	for _, projectType := range r.types {
+		all = append(all, projectType)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all, nil
+}
+
+func (r *MemoryRepository) GetProjectType(name string) (*ProjectType, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	projectType, ok := r.types[name]
+	if !ok {
+		return nil, ErrNotExists
+	}
+	return &projectType, nil
+}
+
+func (r *MemoryRepository) UpdateProjectType(name string, updated ProjectType) (*ProjectType, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.types[name]; !ok {
+		return nil, ErrUpdateFailed
+	}
+	updated.Name = name
+	r.types[name] = updated
+	return &updated, nil
+}
+
+func (r *MemoryRepository) DeleteProjectType(name string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.types[name]; !ok {
+		return ErrDeleteFailed
+	}
+	for _, project := range r.projects {
+		if project.Type == name {
+			return ErrTypeInUse
+		}
+	}
+	delete(r.types, name)
+	return nil
+}
+
+func (r *MemoryRepository) CreateClient(client Client) (*Client, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, existing := range r.clients {
+		if existing.Name == client.Name {
+			return nil, ErrDuplicate
+		}
+	}
+	r.lastClientID++
+	client.ID = r.lastClientID
+	r.clients[client.ID] = client
+	return &client, nil
+}
+
+func (r *MemoryRepository) AllClients() ([]Client, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var all []Client
+	for _, client := range r.clients {
+		all = append(all, client)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all, nil
+}
+
+func (r *MemoryRepository) GetClient(id int64) (*Client, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	client, ok := r.clients[id]
+	if !ok {
+		return nil, ErrNotExists
+	}
+	return &client, nil
+}
+
+func (r *MemoryRepository) UpdateClient(id int64, updated Client) (*Client, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.clients[id]; !ok {
+		return nil, ErrUpdateFailed
+	}
+	for _, existing := range r.clients {
+		if existing.ID != id && existing.Name == updated.Name {
+			return nil, ErrDuplicate
+		}
+	}
+	updated.ID = id
+	r.clients[id] = updated
+	return &updated, nil
+}
+
+func (r *MemoryRepository) DeleteClient(id int64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.clients[id]; !ok {
+		return ErrDeleteFailed
+	}
+	for _, project := range r.projects {
+		if project.ClientID == id {
+			return ErrClientInUse
+		}
+	}
+	delete(r.clients, id)
+	return nil
+}
+
+func (r *MemoryRepository) CreateTask(task Task) (*Task, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.projects[task.ProjectTag]; !ok {
+		return nil, ErrNotExists
+	}
+	for _, existing := range r.tasks {
+		if existing.ProjectTag == task.ProjectTag && existing.Name == task.Name {
+			return nil, ErrDuplicate
+		}
+	}
+	r.lastTaskID++
+	task.ID = r.lastTaskID
+	r.tasks[task.ID] = task
+	return &task, nil
+}
+
+func (r *MemoryRepository) AllTasks() ([]Task, error) {
+	return r.filterTasks(func(Task) bool { return true }), nil
+}
+
+func (r *MemoryRepository) GetTasksByProjectTag(tag string) ([]Task, error) {
+	return r.filterTasks(func(t Task) bool { return t.ProjectTag == tag }), nil
+}
+
+func (r *MemoryRepository) filterTasks(keep func(Task) bool) []Task {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var all []Task
+	for _, task := range r.tasks {
+		if keep(task) {
+			all = append(all, task)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].ProjectTag != all[j].ProjectTag {
+			return all[i].ProjectTag < all[j].ProjectTag
+		}
+		return all[i].Name < all[j].Name
+	})
+	return all
+}
+
+func (r *MemoryRepository) GetTask(id int64) (*Task, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	task, ok := r.tasks[id]
+	if !ok {
+		return nil, ErrNotExists
+	}
+	return &task, nil
+}
+
+func (r *MemoryRepository) UpdateTask(id int64, updated Task) (*Task, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	existing, ok := r.tasks[id]
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	for _, task := range r.tasks {
+		if task.ID != id && task.ProjectTag == existing.ProjectTag && task.Name == updated.Name {
+			return nil, ErrDuplicate
+		}
+	}
+	updated.ID = id
+	updated.ProjectTag = existing.ProjectTag
+	r.tasks[id] = updated
+	return &updated, nil
+}
+
+func (r *MemoryRepository) DeleteTask(id int64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.tasks[id]; !ok {
+		return ErrDeleteFailed
+	}
+	for _, recording := range r.recordings {
+		if recording.TaskID == id {
+			return ErrTaskInUse
+		}
+	}
+	delete(r.tasks, id)
+	return nil
+}
+
+func (r *MemoryRepository) CreateRecording(recording Recording) (*Recording, error) {
+	if recording.StartTime.IsZero() {
+		recording.StartTime = time.Now()
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.projects[recording.ProjectTag]; !ok {
+		return nil, ErrNotExists
+	}
+	if err := r.checkTask(recording); err != nil {
+		return nil, err
+	}
+	if recording.IsRunning() && r.isRunning(0) {
+		return nil, ErrRunning
+	}
+	r.lastID++
+	recording.ID = r.lastID
+	recording.Labels = slices.Clone(recording.Labels)
+	r.recordings[recording.ID] = recording
+	return &recording, nil
+}
+
+func (r *MemoryRepository) AllRecordings() ([]Recording, error) {
+	return r.FindRecordings(RecordingFilter{})
+}
+
+func (r *MemoryRepository) GetRecordingByID(id int64) (*Recording, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	recording, ok := r.recordings[id]
+	if !ok {
+		return nil, ErrNotExists
+	}
+	return &recording, nil
+}
+
+func (r *MemoryRepository) GetRunningRecording() (*Recording, error) {
+	recordings, err := r.FindRecordings(RecordingFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for i := len(recordings) - 1; i >= 0; i-- {
+		if recordings[i].IsRunning() {
+			return &recordings[i], nil
+		}
+	}
+	return nil, ErrNotExists
+}
+
+// isRunning reports whether a recording other than the given one is running.
+func (r *MemoryRepository) isRunning(except int64) bool {
+	for id, recording := range r.recordings {
+		if id != except && recording.IsRunning() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *MemoryRepository) GetRecordingsByProjectTag(tag string) ([]Recording, error) {
+	return r.FindRecordings(RecordingFilter{ProjectTag: tag})
+}
+
+func (r *MemoryRepository) GetRecordingsByDateRange(start, end time.Time) ([]Recording, error) {
+	return r.FindRecordings(RecordingFilter{From: start, To: end})
+}
+
+func (r *MemoryRepository) FindRecordings(filter RecordingFilter) ([]Recording, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var all []Recording
+	for _, recording := range r.recordings {
+		if filter.ProjectTag != "" && recording.ProjectTag != filter.ProjectTag {
+			continue
+		}
+		if !filter.From.IsZero() && !recording.IsRunning() && !recording.EndTime.After(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && !recording.StartTime.Before(filter.To) {
+			continue
+		}
+		if filter.Billable != nil && recording.Billable != *filter.Billable {
+			continue
+		}
+		if filter.Uninvoiced && recording.IsInvoiced() {
+			continue
+		}
+		if filter.Label != "" && !recording.HasLabel(filter.Label) {
+			continue
+		}
+		all = append(all, recording)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].StartTime.Before(all[j].StartTime) })
+	return all, nil
+}
+
+func (r *MemoryRepository) UpdateRecording(id int64, updated Recording) (*Recording, error) {
+	if id == 0 {
+		return nil, errors.New("invalid recording id")
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	existing, ok := r.recordings[id]
+	if !ok {
+		return nil, ErrUpdateFailed
+	}
+	if existing.IsInvoiced() {
+		return nil, ErrInvoiced
+	}
+	if _, ok := r.projects[updated.ProjectTag]; !ok {
+		return nil, ErrNotExists
+	}
+	if err := r.checkTask(updated); err != nil {
+		return nil, err
+	}
+	if updated.IsRunning() && r.isRunning(id) {
+		return nil, ErrRunning
+	}
+	updated.ID = id
+	updated.InvoiceNumber = 0
+	updated.Labels = slices.Clone(updated.Labels)
+	r.recordings[id] = updated
+	return &updated, nil
+}
+
+// AllLabels returns the names of the labels in use, sorted.
+func (r *MemoryRepository) AllLabels() ([]string, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var all []string
+	for _, recording := range r.recordings {
+		for _, label := range recording.Labels {
+			if !slices.Contains(all, label) {
+				all = append(all, label)
+			}
+		}
+	}
+	sort.Strings(all)
+	return all, nil
+}
+
+// checkTask makes sure the task of a recording belongs to its project. The
+// lock has to be held.
+func (r *MemoryRepository) checkTask(recording Recording) error {
+	if recording.TaskID == 0 {
+		return nil
+	}
+	task, ok := r.tasks[recording.TaskID]
+	if !ok {
+		return ErrNotExists
+	}
+	if task.ProjectTag != recording.ProjectTag {
+		return ErrTaskProject
+	}
+	return nil
+}
+
+func (r *MemoryRepository) DeleteRecording(id int64) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	existing, ok := r.recordings[id]
+	if !ok {
+		return ErrDeleteFailed
+	}
+	if existing.IsInvoiced() {
+		return ErrInvoiced
+	}
+	delete(r.recordings, id)
+	return nil
+}
+
+func (r *MemoryRepository) CreateInvoice(invoice Invoice, recordingIDs []int64) (*Invoice, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.projects[invoice.ProjectTag]; !ok {
+		return nil, ErrNotExists
+	}
+	for _, id := range recordingIDs {
+		recording, ok := r.recordings[id]
+		if !ok {
+			return nil, ErrNotExists
+		}
+		if recording.IsInvoiced() {
+			return nil, fmt.Errorf("recording %d: %w", id, ErrInvoiced)
+		}
+	}
+	invoice.Number = 0
+	for number := range r.invoices {
+		if number > invoice.Number {
+			invoice.Number = number
+		}
+	}
+	invoice.Number++
+	r.invoices[invoice.Number] = invoice
+	for _, id := range recordingIDs {
+		recording := r.recordings[id]
+		recording.InvoiceNumber = invoice.Number
+		r.recordings[id] = recording
+	}
+	return &invoice, nil
+}
+
+func (r *MemoryRepository) AllInvoices() ([]Invoice, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var all []Invoice
+	for _, invoice := range r.invoices {
+		all = append(all, invoice)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Number < all[j].Number })
+	return all, nil
+}
+
+func (r *MemoryRepository) GetInvoice(number int64) (*Invoice, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	invoice, ok := r.invoices[number]
+	if !ok {
+		return nil, ErrNotExists
+	}
+	return &invoice, nil
+}
+
+func (r *MemoryRepository) GetRecordingsByInvoice(number int64) ([]Recording, error) {
+	recordings, err := r.FindRecordings(RecordingFilter{})
+	if err != nil {
+		return nil, err
+	}
+	var invoiced []Recording
+	for _, recording := range recordings {
+		if recording.InvoiceNumber == number {
+			invoiced = append(invoiced, recording)
+		}
+	}
+	return invoiced, nil
+}
+
+func (r *MemoryRepository) Import(projects []Project, recordings []Recording, progress func()) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return r.insertAll(Dataset{Projects: projects, Recordings: recordings}, progress)
+}
+
+func (r *MemoryRepository) Restore(data Dataset, replace bool) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if replace {
+		oldTypes, oldClients, oldProjects, oldTasks, oldRecordings, oldInvoices := r.types, r.clients, r.projects, r.tasks, r.recordings, r.invoices
+		r.types, r.clients, r.projects, r.tasks, r.recordings, r.invoices = map[string]ProjectType{}, map[int64]Client{}, map[string]Project{}, map[int64]Task{}, map[int64]Recording{}, map[int64]Invoice{}
+		if err := r.insertAll(data, nil); err != nil {
+			r.types, r.clients, r.projects, r.tasks, r.recordings, r.invoices = oldTypes, oldClients, oldProjects, oldTasks, oldRecordings, oldInvoices
+			return err
+		}
+		return nil
+	}
+	return r.insertAll(data, nil)
+}
+
+// insertAll checks all inserts before storing anything, like a transaction.
+func (r *MemoryRepository) insertAll(data Dataset, progress func()) error {
+	projects, recordings := data.Projects, data.Recordings
+	for _, projectType := range data.ProjectTypes {
+		if _, ok := r.types[projectType.Name]; ok {
+			return ErrDuplicate
+		}
+	}
+	clients := map[int64]bool{}
+	names := map[string]bool{}
+	for id, client := range r.clients {
+		clients[id] = true
+		names[client.Name] = true
+	}
+	for _, client := range data.Clients {
+		if clients[client.ID] || names[client.Name] {
+			return ErrDuplicate
+		}
+		clients[client.ID] = true
+		names[client.Name] = true
+	}
+	known := map[string]bool{}
+	for tag := range r.projects {
+		known[tag] = true
+	}
+	for _, project := range projects {
+		if known[project.Tag] {
+			return ErrDuplicate
+		}
+		if project.ClientID != 0 && !clients[project.ClientID] {
+			return ErrNotExists
+		}
+		known[project.Tag] = true
+	}
+	tasks := map[int64]string{}
+	for id, task := range r.tasks {
+		tasks[id] = task.ProjectTag
+	}
+	for _, task := range data.Tasks {
+		if _, ok := tasks[task.ID]; ok {
+			return ErrDuplicate
+		}
+		if !known[task.ProjectTag] {
+			return ErrNotExists
+		}
+		tasks[task.ID] = task.ProjectTag
+	}
+	invoices := map[int64]bool{}
+	for number := range r.invoices {
+		invoices[number] = true
+	}
+	for _, invoice := range data.Invoices {
+		if invoices[invoice.Number] {
+			return ErrDuplicate
+		}
+		if !known[invoice.ProjectTag] {
+			return ErrNotExists
+		}
+		invoices[invoice.Number] = true
+	}
+	running := 0
+	if r.isRunning(0) {
+		running++
+	}
+	ids := map[int64]bool{}
+	for _, recording := range recordings {
+		if recording.IsRunning() {
+			if running++; running > 1 {
+				return ErrRunning
+			}
+		}
+		if !known[recording.ProjectTag] {
+			return ErrNotExists
+		}
+		if recording.IsInvoiced() && !invoices[recording.InvoiceNumber] {
+			return ErrNotExists
+		}
+		if tag, ok := tasks[recording.TaskID]; recording.TaskID != 0 && (!ok || tag != recording.ProjectTag) {
+			return ErrTaskProject
+		}
+		if _, ok := r.recordings[recording.ID]; ok || (recording.ID != 0 && ids[recording.ID]) {
+			return ErrDuplicate
+		}
+		ids[recording.ID] = true
+	}
+
+	for _, projectType := range data.ProjectTypes {
+		r.types[projectType.Name] = projectType
+	}
+	for _, client := range data.Clients {
+		r.clients[client.ID] = client
+		if client.ID > r.lastClientID {
+			r.lastClientID = client.ID
+		}
+	}
+	for _, project := range projects {
+		r.projects[project.Tag] = project
+		if _, ok := r.types[project.Type]; !ok {
+			r.types[project.Type] = ProjectType{Name: project.Type}
+		}
+	}
+	for _, task := range data.Tasks {
+		r.tasks[task.ID] = task
+		if task.ID > r.lastTaskID {
+			r.lastTaskID = task.ID
+		}
+	}
+	for _, invoice := range data.Invoices {
+		r.invoices[invoice.Number] = invoice
+	}
+	for _, recording := range recordings {
+		if recording.ID > r.lastID {
+			r.lastID = recording.ID
+		}
+	}
+	for _, recording := range recordings {
+		if recording.ID == 0 {
+			r.lastID++
+			recording.ID = r.lastID
+		}
+		recording.Labels = slices.Clone(recording.Labels)
+		r.recordings[recording.ID] = recording
+		if progress != nil {
+			progress()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,420 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Migration is a numbered schema change. Migrations are applied in order of
+// their version and never changed once released; add a new one instead.
+type Migration struct {
+	Version     int
+	Description string
+	sqlite      string
+	postgres    string
+}
+
+// MigrationStatus tells whether a migration has been applied to the database.
+type MigrationStatus struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create project and record tables",
+		sqlite: `
+		CREATE TABLE IF NOT EXISTS project(
+			tag  VARCHAR(20) PRIMARY KEY UNIQUE,
+			name VARCHAR(50) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			status INTEGER NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS record(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			projTag VARCHAR(20) NOT NULL,
+			startTime DATETIME NOT NULL,
+			endTime DATETIME,
+			name VARCHAR(70) NOT NULL,
+			billable BOOLEAN,
+			note TEXT,
+			status INTEGER NOT NULL
+		);
+		`,
+		postgres: `
+		CREATE TABLE IF NOT EXISTS project(
+			tag  VARCHAR(20) PRIMARY KEY,
+			name VARCHAR(50) NOT NULL,
+			type VARCHAR(20) NOT NULL,
+			status INTEGER NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS record(
+			id BIGSERIAL PRIMARY KEY,
+			projTag VARCHAR(20) NOT NULL,
+			startTime TIMESTAMPTZ NOT NULL,
+			endTime TIMESTAMPTZ,
+			name VARCHAR(70) NOT NULL,
+			billable BOOLEAN,
+			note TEXT,
+			status INTEGER NOT NULL
+		);
+		`,
+	},
+	{
+		Version:     2,
+		Description: "index recordings by start time",
+		sqlite:      `CREATE INDEX IF NOT EXISTS record_startTime ON record(startTime);`,
+		postgres:    `CREATE INDEX IF NOT EXISTS record_startTime ON record(startTime);`,
+	},
+	{
+		Version:     3,
+		Description: "reference projects from recordings",
+		// Recordings of deleted projects get an inactive placeholder project,
+		// SQLite needs the table to be rebuilt to add the foreign key.
+		sqlite: `
+		INSERT INTO project(tag, name, type, status)
+			SELECT DISTINCT projTag, projTag, 'other', 1 FROM record WHERE projTag NOT IN (SELECT tag FROM project);
+
+		CREATE TABLE record_new(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			projTag VARCHAR(20) NOT NULL REFERENCES project(tag) ON UPDATE CASCADE,
+			startTime DATETIME NOT NULL,
+			endTime DATETIME,
+			name VARCHAR(70) NOT NULL,
+			billable BOOLEAN,
+			note TEXT,
+			status INTEGER NOT NULL
+		);
+		INSERT INTO record_new(id, projTag, startTime, endTime, name, billable, note, status)
+			SELECT id, projTag, startTime, endTime, name, billable, note, status FROM record;
+		DROP TABLE record;
+		ALTER TABLE record_new RENAME TO record;
+
+		CREATE INDEX record_startTime ON record(startTime);
+		CREATE INDEX record_projTag ON record(projTag);
+		`,
+		postgres: `
+		INSERT INTO project(tag, name, type, status)
+			SELECT DISTINCT projTag, projTag, 'other', 1 FROM record WHERE projTag NOT IN (SELECT tag FROM project);
+
+		ALTER TABLE record ADD CONSTRAINT record_projTag_fkey FOREIGN KEY (projTag) REFERENCES project(tag) ON UPDATE CASCADE;
+		CREATE INDEX record_projTag ON record(projTag);
+		`,
+	},
+	{
+		Version:     4,
+		Description: "add hourly rates to projects and recordings",
+		// a NULL rate on a recording means the project's rate applies
+		sqlite: `
+		ALTER TABLE project ADD COLUMN hourlyRate REAL NOT NULL DEFAULT 0;
+		ALTER TABLE record ADD COLUMN hourlyRate REAL;
+		`,
+		postgres: `
+		ALTER TABLE project ADD COLUMN hourlyRate NUMERIC(10, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE record ADD COLUMN hourlyRate NUMERIC(10, 2);
+		`,
+	},
+	{
+		Version:     5,
+		Description: "add invoices",
+		sqlite: `
+		CREATE TABLE invoice(
+			number INTEGER PRIMARY KEY,
+			projTag VARCHAR(20) NOT NULL REFERENCES project(tag) ON UPDATE CASCADE,
+			issuedAt DATETIME NOT NULL,
+			periodStart DATETIME NOT NULL,
+			periodEnd DATETIME NOT NULL,
+			currency VARCHAR(3) NOT NULL,
+			amount REAL NOT NULL
+		);
+		ALTER TABLE record ADD COLUMN invoiceNumber INTEGER REFERENCES invoice(number);
+		CREATE INDEX record_invoiceNumber ON record(invoiceNumber);
+		`,
+		postgres: `
+		CREATE TABLE invoice(
+			number BIGINT PRIMARY KEY,
+			projTag VARCHAR(20) NOT NULL REFERENCES project(tag) ON UPDATE CASCADE,
+			issuedAt TIMESTAMPTZ NOT NULL,
+			periodStart TIMESTAMPTZ NOT NULL,
+			periodEnd TIMESTAMPTZ NOT NULL,
+			currency VARCHAR(3) NOT NULL,
+			amount NUMERIC(12, 2) NOT NULL
+		);
+		ALTER TABLE record ADD COLUMN invoiceNumber BIGINT REFERENCES invoice(number);
+		CREATE INDEX record_invoiceNumber ON record(invoiceNumber);
+		`,
+	},
+	{
+		Version:     6,
+		Description: "add clients owning projects",
+		sqlite: `
+		CREATE TABLE client(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(50) NOT NULL UNIQUE,
+			address TEXT NOT NULL DEFAULT '',
+			vatId VARCHAR(20) NOT NULL DEFAULT '',
+			hourlyRate REAL NOT NULL DEFAULT 0,
+			currency VARCHAR(3) NOT NULL DEFAULT ''
+		);
+		ALTER TABLE project ADD COLUMN clientId INTEGER REFERENCES client(id);
+		CREATE INDEX project_clientId ON project(clientId);
+		`,
+		postgres: `
+		CREATE TABLE client(
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(50) NOT NULL UNIQUE,
+			address TEXT NOT NULL DEFAULT '',
+			vatId VARCHAR(20) NOT NULL DEFAULT '',
+			hourlyRate NUMERIC(10, 2) NOT NULL DEFAULT 0,
+			currency VARCHAR(3) NOT NULL DEFAULT ''
+		);
+		ALTER TABLE project ADD COLUMN clientId INTEGER REFERENCES client(id);
+		CREATE INDEX project_clientId ON project(clientId);
+		`,
+	},
+	{
+		Version:     7,
+		Description: "add tasks within projects",
+		// estimates are stored in minutes
+		sqlite: `
+		CREATE TABLE task(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			projTag VARCHAR(20) NOT NULL REFERENCES project(tag) ON UPDATE CASCADE ON DELETE CASCADE,
+			name VARCHAR(70) NOT NULL,
+			estimate INTEGER NOT NULL DEFAULT 0,
+			done BOOLEAN NOT NULL DEFAULT FALSE,
+			UNIQUE(projTag, name)
+		);
+		ALTER TABLE record ADD COLUMN taskId INTEGER REFERENCES task(id);
+		CREATE INDEX record_taskId ON record(taskId);
+		`,
+		postgres: `
+		CREATE TABLE task(
+			id SERIAL PRIMARY KEY,
+			projTag VARCHAR(20) NOT NULL REFERENCES project(tag) ON UPDATE CASCADE ON DELETE CASCADE,
+			name VARCHAR(70) NOT NULL,
+			estimate INTEGER NOT NULL DEFAULT 0,
+			done BOOLEAN NOT NULL DEFAULT FALSE,
+			UNIQUE(projTag, name)
+		);
+		ALTER TABLE record ADD COLUMN taskId INTEGER REFERENCES task(id);
+		CREATE INDEX record_taskId ON record(taskId);
+		`,
+	},
+	{
+		Version:     8,
+		Description: "add budgets to projects",
+		sqlite: `
+		ALTER TABLE project ADD COLUMN budgetHours REAL NOT NULL DEFAULT 0;
+		ALTER TABLE project ADD COLUMN budgetAmount REAL NOT NULL DEFAULT 0;
+		ALTER TABLE project ADD COLUMN budgetPeriod VARCHAR(10) NOT NULL DEFAULT '';
+		`,
+		postgres: `
+		ALTER TABLE project ADD COLUMN budgetHours NUMERIC(10, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE project ADD COLUMN budgetAmount NUMERIC(12, 2) NOT NULL DEFAULT 0;
+		ALTER TABLE project ADD COLUMN budgetPeriod VARCHAR(10) NOT NULL DEFAULT '';
+		`,
+	},
+	{
+		Version:     9,
+		Description: "add labels on recordings",
+		sqlite: `
+		CREATE TABLE label(
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			name VARCHAR(30) NOT NULL UNIQUE
+		);
+		CREATE TABLE record_label(
+			recordId INTEGER NOT NULL REFERENCES record(id) ON DELETE CASCADE,
+			labelId INTEGER NOT NULL REFERENCES label(id) ON DELETE CASCADE,
+			PRIMARY KEY(recordId, labelId)
+		);
+		CREATE INDEX record_label_labelId ON record_label(labelId);
+		`,
+		postgres: `
+		CREATE TABLE label(
+			id SERIAL PRIMARY KEY,
+			name VARCHAR(30) NOT NULL UNIQUE
+		);
+		CREATE TABLE record_label(
+			recordId BIGINT NOT NULL REFERENCES record(id) ON DELETE CASCADE,
+			labelId INTEGER NOT NULL REFERENCES label(id) ON DELETE CASCADE,
+			PRIMARY KEY(recordId, labelId)
+		);
+		CREATE INDEX record_label_labelId ON record_label(labelId);
+		`,
+	},
+	{
+		Version:     10,
+		Description: "store project types",
+		// the types of existing projects are kept, without defaults
+		sqlite: `
+		CREATE TABLE project_type(
+			name VARCHAR(20) PRIMARY KEY,
+			billableDefault BOOLEAN NOT NULL DEFAULT FALSE,
+			color VARCHAR(10) NOT NULL DEFAULT ''
+		);
+		INSERT INTO project_type(name, billableDefault, color) VALUES
+			('customer', TRUE, 'green'),
+			('development', FALSE, 'yellow'),
+			('internal', FALSE, 'blue'),
+			('open source', FALSE, 'magenta'),
+			('other', FALSE, '');
+		INSERT INTO project_type(name) SELECT DISTINCT type FROM project WHERE type NOT IN (SELECT name FROM project_type);
+		`,
+		postgres: `
+		CREATE TABLE project_type(
+			name VARCHAR(20) PRIMARY KEY,
+			billableDefault BOOLEAN NOT NULL DEFAULT FALSE,
+			color VARCHAR(10) NOT NULL DEFAULT ''
+		);
+		INSERT INTO project_type(name, billableDefault, color) VALUES
+			('customer', TRUE, 'green'),
+			('development', FALSE, 'yellow'),
+			('internal', FALSE, 'blue'),
+			('open source', FALSE, 'magenta'),
+			('other', FALSE, '');
+		INSERT INTO project_type(name) SELECT DISTINCT type FROM project WHERE type NOT IN (SELECT name FROM project_type);
+		`,
+	},
+	{
+		Version:     11,
+		Description: "turn inactive projects into archived ones",
+		// 1 was inactive and is on hold now, archived is 4
+		sqlite:   `UPDATE project SET status = 4 WHERE status = 1;`,
+		postgres: `UPDATE project SET status = 4 WHERE status = 1;`,
+	},
+	{
+		Version:     12,
+		Description: "store invoice lines",
+		sqlite: `
+		CREATE TABLE invoice_line(
+			invoiceNumber INTEGER NOT NULL REFERENCES invoice(number) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			day DATETIME NOT NULL,
+			name VARCHAR(70) NOT NULL,
+			hours REAL NOT NULL,
+			rate REAL NOT NULL,
+			amount REAL NOT NULL,
+			PRIMARY KEY (invoiceNumber, position)
+		);
+		`,
+		postgres: `
+		CREATE TABLE invoice_line(
+			invoiceNumber BIGINT NOT NULL REFERENCES invoice(number) ON DELETE CASCADE,
+			position INTEGER NOT NULL,
+			day TIMESTAMPTZ NOT NULL,
+			name VARCHAR(70) NOT NULL,
+			hours NUMERIC(10, 2) NOT NULL,
+			rate NUMERIC(10, 2) NOT NULL,
+			amount NUMERIC(12, 2) NOT NULL,
+			PRIMARY KEY (invoiceNumber, position)
+		);
+		`,
+	},
+}
+
+func (r *SQLRepository) createSchemaVersionTable() error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_version(
+		version INTEGER PRIMARY KEY,
+		description VARCHAR(100) NOT NULL,
+		appliedAt DATETIME NOT NULL
+	);
+	`
+	if r.dialect == dialectPostgres {
+		query = `
+		CREATE TABLE IF NOT EXISTS schema_version(
+			version INTEGER PRIMARY KEY,
+			description VARCHAR(100) NOT NULL,
+			appliedAt TIMESTAMPTZ NOT NULL
+		);
+		`
+	}
+	_, err := r.db.Exec(query)
+	return err
+}
+
+// MigrationStatus lists all known migrations and whether they have been
+// applied.
+func (r *SQLRepository) MigrationStatus() ([]MigrationStatus, error) {
+	if err := r.createSchemaVersionTable(); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.query("SELECT version, appliedAt FROM schema_version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var appliedAt time.Time
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var all []MigrationStatus
+	for _, migration := range migrations {
+		appliedAt, ok := applied[migration.Version]
+		all = append(all, MigrationStatus{Migration: migration, Applied: ok, AppliedAt: appliedAt})
+	}
+	return all, nil
+}
+
+// Migrate applies all pending migrations in a single transaction, so a
+// failing migration leaves the schema untouched.
+func (r *SQLRepository) Migrate() error {
+	status, err := r.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, migration := range status {
+		if migration.Applied {
+			continue
+		}
+		query := migration.sqlite
+		if r.dialect == dialectPostgres {
+			query = migration.postgres
+		}
+		if _, err := tx.Exec(query); err != nil {
+			return fmt.Errorf("migration %d (%s): %w", migration.Version, migration.Description, err)
+		}
+		if _, err := tx.Exec(r.rebind("INSERT INTO schema_version(version, description, appliedAt) values(?,?,?)"), migration.Version, migration.Description, time.Now()); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// PendingMigrations returns the migrations Migrate would apply.
+func PendingMigrations(repo Repository) ([]Migration, error) {
+	status, err := repo.MigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, migration := range status {
+		if !migration.Applied {
+			pending = append(pending, migration.Migration)
+		}
+	}
+	return pending, nil
+}
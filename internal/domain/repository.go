@@ -3,7 +3,14 @@ package domain
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/lib/pq"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 var (
@@ -11,177 +18,707 @@ var (
 	ErrNotExists    = errors.New("row not exists")
 	ErrUpdateFailed = errors.New("update failed")
 	ErrDeleteFailed = errors.New("delete failed")
+	ErrProjectInUse = errors.New("project has recordings")
+	ErrInvoiced     = errors.New("recording is invoiced")
+	ErrClientInUse  = errors.New("client has projects")
+	ErrTaskInUse    = errors.New("task has recordings")
+	ErrTaskProject  = errors.New("task belongs to another project")
+	ErrTypeInUse    = errors.New("project type has projects")
+	ErrRunning      = errors.New("another recording is running")
+	// ErrStatusTransition is returned for project status changes that
+	// ProjectStatus.CanBecome doesn't allow.
+	ErrStatusTransition = errors.New("project status can't change")
+	// ErrProjectClosed is returned when starting a recording on a project
+	// that isn't ProjectStatus.Trackable.
+	ErrProjectClosed = errors.New("project is completed or archived")
 )
 
-type SQLiteRepository struct {
-	db *sql.DB
-}
-
-func NewSQLiteRepository(db *sql.DB) *SQLiteRepository {
-	return &SQLiteRepository{
-		db: db,
-	}
-}
-
-func (r *SQLiteRepository) Migrate() error {
-	query := `
-	CREATE TABLE IF NOT EXISTS project(
-		tag  VARCHAR(20) PRIMARY KEY UNIQUE,
-		name VARCHAR(50) NOT NULL,
-		type VARCHAR(20) NOT NULL,
-		status INTEGER NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS record(
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		projTag VARCHAR(20) NOT NULL,
-		startTime DATETIME NOT NULL,
-		endTime DATETIME,
-		name VARCHAR(70) NOT NULL,
-		billable BOOLEAN,
-		note TEXT,
-		status INTEGER NOT NULL
-	);
-	`
-	_, err := r.db.Exec(query)
-	return err
+// Repository is the storage of projects and recordings.
+type Repository interface {
+	Migrate() error
+	MigrationStatus() ([]MigrationStatus, error)
+
+	CreateProject(project Project) (*Project, error)
+	AllProjects() ([]Project, error)
+	AllActiveProjects() ([]Project, error)
+	GetProjectByTag(tag string) (*Project, error)
+	UpdateProject(tag string, updated Project) (*Project, error)
+	DeleteProject(tag string) error
+	CountRecordingsByProjectTag(tag string) (int, error)
+	ReassignRecordings(fromTag, toTag string) (int64, error)
+
+	CreateProjectType(projectType ProjectType) (*ProjectType, error)
+	AllProjectTypes() ([]ProjectType, error)
+	GetProjectType(name string) (*ProjectType, error)
+	UpdateProjectType(name string, updated ProjectType) (*ProjectType, error)
+	DeleteProjectType(name string) error
+
+	CreateClient(client Client) (*Client, error)
+	AllClients() ([]Client, error)
+	GetClient(id int64) (*Client, error)
+	UpdateClient(id int64, updated Client) (*Client, error)
+	DeleteClient(id int64) error
+
+	CreateTask(task Task) (*Task, error)
+	AllTasks() ([]Task, error)
+	GetTasksByProjectTag(tag string) ([]Task, error)
+	GetTask(id int64) (*Task, error)
+	UpdateTask(id int64, updated Task) (*Task, error)
+	DeleteTask(id int64) error
+
+	CreateRecording(recording Recording) (*Recording, error)
+	AllRecordings() ([]Recording, error)
+	GetRecordingByID(id int64) (*Recording, error)
+	GetRunningRecording() (*Recording, error)
+	GetRecordingsByProjectTag(tag string) ([]Recording, error)
+	GetRecordingsByDateRange(start, end time.Time) ([]Recording, error)
+	FindRecordings(filter RecordingFilter) ([]Recording, error)
+	UpdateRecording(id int64, updated Recording) (*Recording, error)
+	DeleteRecording(id int64) error
+
+	AllLabels() ([]string, error)
+
+	CreateInvoice(invoice Invoice, recordingIDs []int64) (*Invoice, error)
+	AllInvoices() ([]Invoice, error)
+	GetInvoice(number int64) (*Invoice, error)
+	GetRecordingsByInvoice(number int64) ([]Recording, error)
+
+	Import(projects []Project, recordings []Recording, progress func()) error
+	Restore(data Dataset, replace bool) error
 }
 
-func (r *SQLiteRepository) CreateProject(project Project) (*Project, error) {
-	_, err := r.db.Exec("INSERT INTO project(tag, name, type, status) values(?,?,?,?)", project.Tag, project.Name, project.Type, 0)
-	if err != nil {
+// Dataset is all stored data, as written to and read from backups.
+type Dataset struct {
+	ProjectTypes []ProjectType
+	Clients      []Client
+	Projects     []Project
+	Tasks        []Task
+	Recordings   []Recording
+	Invoices     []Invoice
+}
+
+type dialect int
+
+const (
+	dialectSQLite dialect = iota
+	dialectPostgres
+)
+
+// SQLRepository stores projects and recordings in a SQL database. Queries are
+// written with "?" placeholders and rewritten for the database's dialect.
+type SQLRepository struct {
+	db      *sql.DB
+	dialect dialect
+}
+
+var _ Repository = (*SQLRepository)(nil)
+
+func NewSQLiteRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{
+		db:      db,
+		dialect: dialectSQLite,
+	}
+}
+
+func NewPostgresRepository(db *sql.DB) *SQLRepository {
+	return &SQLRepository{
+		db:      db,
+		dialect: dialectPostgres,
+	}
+}
+
+// rebind replaces the "?" placeholders of a query with "$1", "$2", ... for
+// PostgreSQL.
+func (r *SQLRepository) rebind(query string) string {
+	if r.dialect != dialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+		} else {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
+
+func (r *SQLRepository) exec(query string, args ...any) (sql.Result, error) {
+	return r.db.Exec(r.rebind(query), args...)
+}
+
+func (r *SQLRepository) query(query string, args ...any) (*sql.Rows, error) {
+	return r.db.Query(r.rebind(query), args...)
+}
+
+func (r *SQLRepository) queryRow(query string, args ...any) *sql.Row {
+	return r.db.QueryRow(r.rebind(query), args...)
+}
+
+// Columns in the order the scan functions read them.
+const (
+	projectColumns   = "tag, name, type, status, hourlyRate, clientId, budgetHours, budgetAmount, budgetPeriod"
+	clientColumns    = "id, name, address, vatId, hourlyRate, currency"
+	typeColumns      = "name, billableDefault, color"
+	recordingColumns = "id, projTag, startTime, endTime, name, billable, note, status, hourlyRate, invoiceNumber, taskId"
+	taskColumns      = "id, projTag, name, estimate, done"
+	invoiceColumns   = "number, projTag, issuedAt, periodStart, periodEnd, currency, amount"
+	// invoiceLineColumns leave out the invoice and position of a line
+	invoiceLineColumns = "day, name, hours, rate, amount"
+)
+
+// CreateProject stores a project. Tags are unique, a taken tag returns
+// ErrDuplicate.
+func (r *SQLRepository) CreateProject(project Project) (*Project, error) {
+	if !project.Status.Valid() {
+		return nil, fmt.Errorf("invalid project status %d", project.Status)
+	}
+	_, err := r.exec("INSERT INTO project("+projectColumns+") values(?,?,?,?,?,?,?,?,?)", project.Tag, project.Name, project.Type, project.Status, project.HourlyRate, nullID(project.ClientID), project.BudgetHours, project.BudgetAmount, project.BudgetPeriod)
+	if isUniqueViolation(err) {
+		return nil, ErrDuplicate
+	} else if err != nil {
 		return nil, err
 	}
 
 	return &project, nil
 }
 
-func (r *SQLiteRepository) CreateRecording(recording Recording) (*Recording, error) {
-	if recording.StartTime.IsZero() {
-		recording.StartTime = time.Now()
+// CreateProjectType stores a project type. Names are unique, a taken name
+// returns ErrDuplicate.
+func (r *SQLRepository) CreateProjectType(projectType ProjectType) (*ProjectType, error) {
+	if _, err := r.GetProjectType(projectType.Name); err == nil {
+		return nil, ErrDuplicate
+	} else if !errors.Is(err, ErrNotExists) {
+		return nil, err
 	}
-	res, err := r.db.Exec("INSERT INTO record(projTag, startTime, name, billable, note, status) values(?,?,?,?,?,?)", recording.ProjectTag, recording.StartTime, recording.Name, recording.Billable, recording.Note, recording.Status)
+	if _, err := r.exec("INSERT INTO project_type("+typeColumns+") values(?,?,?)", projectType.Name, projectType.BillableDefault, projectType.Color); err != nil {
+		return nil, err
+	}
+	return &projectType, nil
+}
+
+func (r *SQLRepository) AllProjectTypes() ([]ProjectType, error) {
+	rows, err := r.query("SELECT " + typeColumns + " FROM project_type ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	id, err := res.LastInsertId()
+	var all []ProjectType
+	for rows.Next() {
+		projectType, err := scanProjectType(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *projectType)
+	}
+	return all, nil
+}
+
+func (r *SQLRepository) GetProjectType(name string) (*ProjectType, error) {
+	projectType, err := scanProjectType(r.queryRow("SELECT "+typeColumns+" FROM project_type WHERE name = ?", name))
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
 		return nil, err
 	}
-	recording.ID = id
+	return projectType, nil
+}
 
-	return &recording, nil
+// UpdateProjectType changes the defaults of a project type. Types can't be
+// renamed.
+func (r *SQLRepository) UpdateProjectType(name string, updated ProjectType) (*ProjectType, error) {
+	res, err := r.exec("UPDATE project_type SET billableDefault = ?, color = ? WHERE name = ?", updated.BillableDefault, updated.Color, name)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrUpdateFailed
+	}
+
+	updated.Name = name
+	return &updated, nil
+}
+
+// DeleteProjectType deletes a project type no project has. Types that are
+// still in use return ErrTypeInUse.
+func (r *SQLRepository) DeleteProjectType(name string) error {
+	var count int
+	if err := r.queryRow("SELECT COUNT(*) FROM project WHERE type = ?", name).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTypeInUse
+	}
+
+	res, err := r.exec("DELETE FROM project_type WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return nil
+}
+
+// CreateClient stores a client under a new ID.
+func (r *SQLRepository) CreateClient(client Client) (*Client, error) {
+	row := r.queryRow("INSERT INTO client(name, address, vatId, hourlyRate, currency) values(?,?,?,?,?) RETURNING id", client.Name, client.Address, client.VATID, client.HourlyRate, client.Currency)
+	if err := row.Scan(&client.ID); err != nil {
+		return nil, err
+	}
+	return &client, nil
 }
 
-func (r *SQLiteRepository) AllProjects() ([]Project, error) {
-	rows, err := r.db.Query("SELECT * FROM project")
+func (r *SQLRepository) AllClients() ([]Client, error) {
+	rows, err := r.query("SELECT " + clientColumns + " FROM client ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var all []Project
+	var all []Client
 	for rows.Next() {
-		var project Project
-		if err := rows.Scan(&project.Tag, &project.Name, &project.Type, &project.Status); err != nil {
+		client, err := scanClient(rows)
+		if err != nil {
 			return nil, err
 		}
-		all = append(all, project)
+		all = append(all, *client)
 	}
 	return all, nil
 }
 
-func (r *SQLiteRepository) AllActiveProjects() ([]Project, error) {
-	rows, err := r.db.Query("SELECT * FROM project WHERE status = 0")
+func (r *SQLRepository) GetClient(id int64) (*Client, error) {
+	client, err := scanClient(r.queryRow("SELECT "+clientColumns+" FROM client WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	return client, nil
+}
+
+func (r *SQLRepository) UpdateClient(id int64, updated Client) (*Client, error) {
+	res, err := r.exec("UPDATE client SET name = ?, address = ?, vatId = ?, hourlyRate = ?, currency = ? WHERE id = ?", updated.Name, updated.Address, updated.VATID, updated.HourlyRate, updated.Currency, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrUpdateFailed
+	}
+
+	updated.ID = id
+	return &updated, nil
+}
+
+// DeleteClient deletes a client without projects. Clients that still own
+// projects return ErrClientInUse.
+func (r *SQLRepository) DeleteClient(id int64) error {
+	var count int
+	if err := r.queryRow("SELECT COUNT(*) FROM project WHERE clientId = ?", id).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrClientInUse
+	}
+
+	res, err := r.exec("DELETE FROM client WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return nil
+}
+
+// CreateTask stores a task under a new ID.
+func (r *SQLRepository) CreateTask(task Task) (*Task, error) {
+	row := r.queryRow("INSERT INTO task(projTag, name, estimate, done) values(?,?,?,?) RETURNING id", task.ProjectTag, task.Name, int64(task.Estimate/time.Minute), task.Done)
+	if err := row.Scan(&task.ID); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func (r *SQLRepository) insertTask(tx *sql.Tx, task Task) error {
+	_, err := tx.Exec(r.rebind("INSERT INTO task("+taskColumns+") values(?,?,?,?,?)"), task.ID, task.ProjectTag, task.Name, int64(task.Estimate/time.Minute), task.Done)
+	return err
+}
+
+func (r *SQLRepository) AllTasks() ([]Task, error) {
+	return r.queryTasks("SELECT " + taskColumns + " FROM task ORDER BY projTag, name")
+}
+
+func (r *SQLRepository) GetTasksByProjectTag(tag string) ([]Task, error) {
+	return r.queryTasks("SELECT "+taskColumns+" FROM task WHERE projTag = ? ORDER BY name", tag)
+}
+
+func (r *SQLRepository) queryTasks(query string, args ...any) ([]Task, error) {
+	rows, err := r.query(query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var all []Project
+	var all []Task
 	for rows.Next() {
-		var project Project
-		if err := rows.Scan(&project.Tag, &project.Name, &project.Type, &project.Status); err != nil {
+		task, err := scanTask(rows)
+		if err != nil {
 			return nil, err
 		}
-		all = append(all, project)
+		all = append(all, *task)
 	}
 	return all, nil
 }
 
-func (r *SQLiteRepository) AllRecordings() ([]Recording, error) {
-	rows, err := r.db.Query("SELECT * FROM record")
+func (r *SQLRepository) GetTask(id int64) (*Task, error) {
+	task, err := scanTask(r.queryRow("SELECT "+taskColumns+" FROM task WHERE id = ?", id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	return task, nil
+}
+
+// UpdateTask changes the name, estimate and done flag of a task. Tasks can't
+// be moved to another project.
+func (r *SQLRepository) UpdateTask(id int64, updated Task) (*Task, error) {
+	res, err := r.exec("UPDATE task SET name = ?, estimate = ?, done = ? WHERE id = ?", updated.Name, int64(updated.Estimate/time.Minute), updated.Done, id)
+	if err != nil {
+		return nil, err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+
+	if rowsAffected == 0 {
+		return nil, ErrUpdateFailed
+	}
+
+	return r.GetTask(id)
+}
+
+// DeleteTask deletes a task without recordings. Tasks that still have
+// recordings return ErrTaskInUse.
+func (r *SQLRepository) DeleteTask(id int64) error {
+	var count int
+	if err := r.queryRow("SELECT COUNT(*) FROM record WHERE taskId = ?", id).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrTaskInUse
+	}
+
+	res, err := r.exec("DELETE FROM task WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeleteFailed
+	}
+
+	return nil
+}
+
+func (r *SQLRepository) CreateRecording(recording Recording) (*Recording, error) {
+	if recording.StartTime.IsZero() {
+		recording.StartTime = time.Now()
+	}
+	if err := r.checkTask(recording); err != nil {
+		return nil, err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if recording.IsRunning() {
+		if err := r.checkRunning(tx, 0); err != nil {
+			return nil, err
+		}
+	}
+	if err := r.insertRecording(tx, &recording); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &recording, nil
+}
+
+// insertRecording stores a recording and its labels. A recording without an
+// ID gets a new one.
+func (r *SQLRepository) insertRecording(tx *sql.Tx, recording *Recording) error {
+	if recording.ID == 0 {
+		row := tx.QueryRow(r.rebind("INSERT INTO record(projTag, startTime, endTime, name, billable, note, status, hourlyRate, taskId) values(?,?,?,?,?,?,?,?,?) RETURNING id"), recording.ProjectTag, recording.StartTime, nullTime(recording.EndTime), recording.Name, recording.Billable, recording.Note, recording.Status, nullRate(recording.HourlyRate), nullID(recording.TaskID))
+		if err := row.Scan(&recording.ID); err != nil {
+			return err
+		}
+	} else {
+		_, err := tx.Exec(r.rebind("INSERT INTO record("+recordingColumns+") values(?,?,?,?,?,?,?,?,?,?,?)"), recording.ID, recording.ProjectTag, recording.StartTime, nullTime(recording.EndTime), recording.Name, recording.Billable, recording.Note, recording.Status, nullRate(recording.HourlyRate), nullID(recording.InvoiceNumber), nullID(recording.TaskID))
+		if err != nil {
+			return err
+		}
+	}
+	return r.setLabels(tx, recording.ID, recording.Labels)
+}
+
+// setLabels replaces the labels of a recording, creating the labels that
+// don't exist yet.
+func (r *SQLRepository) setLabels(tx *sql.Tx, id int64, labels []string) error {
+	if _, err := tx.Exec(r.rebind("DELETE FROM record_label WHERE recordId = ?"), id); err != nil {
+		return err
+	}
+	for _, label := range labels {
+		if _, err := tx.Exec(r.rebind("INSERT INTO label(name) values(?) ON CONFLICT(name) DO NOTHING"), label); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.rebind("INSERT INTO record_label(recordId, labelId) SELECT ?, id FROM label WHERE name = ?"), id, label); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AllLabels returns the names of the labels in use, sorted.
+func (r *SQLRepository) AllLabels() ([]string, error) {
+	rows, err := r.query("SELECT name FROM label WHERE id IN (SELECT labelId FROM record_label) ORDER BY name")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var all []Recording
+	var all []string
 	for rows.Next() {
-		var recording Recording
-		if err := rows.Scan(&recording.ID, &recording.ProjectTag, &recording.StartTime, &recording.EndTime, &recording.Name, &recording.Billable, &recording.Note, &recording.Status); err != nil {
+		var name string
+		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
-		all = append(all, recording)
+		all = append(all, name)
 	}
 	return all, nil
 }
 
-func (r *SQLiteRepository) GetProjectByTag(tag string) (*Project, error) {
-	row := r.db.QueryRow("SELECT * FROM project WHERE tag = ?", tag)
-	
-	var project Project
-	if err := row.Scan(&project.Tag, &project.Name, &project.Type, &project.Status); err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrNotExists
-		}
+func (r *SQLRepository) AllProjects() ([]Project, error) {
+	rows, err := r.query("SELECT " + projectColumns + " FROM project")
+	if err != nil {
 		return nil, err
 	}
-	return &project, nil
+	defer rows.Close()
+
+	var all []Project
+	for rows.Next() {
+		project, err := scanProject(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *project)
+	}
+	return all, nil
 }
 
-func (r *SQLiteRepository) GetRecordingsByProjectTag(tag string) ([]Recording, error) {
-	rows, err := r.db.Query("SELECT * FROM record projTag = ?", tag)
+func (r *SQLRepository) AllActiveProjects() ([]Project, error) {
+	rows, err := r.query("SELECT "+projectColumns+" FROM project WHERE status = ?", StatusActive)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var all []Recording
+	var all []Project
 	for rows.Next() {
-		var recording Recording
-		if err := rows.Scan(&recording.ID, &recording.ProjectTag, &recording.StartTime, &recording.EndTime, &recording.Name, &recording.Billable, &recording.Note, &recording.Status); err != nil {
+		project, err := scanProject(rows)
+		if err != nil {
 			return nil, err
 		}
-		all = append(all, recording)
+		all = append(all, *project)
 	}
 	return all, nil
 }
 
-func (r *SQLiteRepository) GetRecordingsByDateRange(start, end time.Time) ([]Recording, error) {
-	rows, err := r.db.Query("SELECT * FROM record startTime >= ? AND endTime <= ?", start, end)
+func (r *SQLRepository) AllRecordings() ([]Recording, error) {
+	return r.queryRecordings("")
+}
+
+func (r *SQLRepository) GetRecordingByID(id int64) (*Recording, error) {
+	return r.getRecording("WHERE id = ?", id)
+}
+
+// queryRecordings returns the recordings selected by the condition, which
+// follows "FROM record" and may order and limit them, with their labels.
+func (r *SQLRepository) queryRecordings(condition string, args ...any) ([]Recording, error) {
+	rows, err := r.query("SELECT "+recordingColumns+" FROM record "+condition, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	var all []Recording
+	index := map[int64]int{}
 	for rows.Next() {
-		var recording Recording
-		if err := rows.Scan(&recording.ID, &recording.ProjectTag, &recording.StartTime, &recording.EndTime, &recording.Name, &recording.Billable, &recording.Note, &recording.Status); err != nil {
+		recording, err := scanRecording(rows)
+		if err != nil {
 			return nil, err
 		}
-		all = append(all, recording)
+		index[recording.ID] = len(all)
+		all = append(all, *recording)
 	}
-	return all, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if len(all) == 0 {
+		return all, nil
+	}
+
+	labels, err := r.query("SELECT rl.recordId, l.name FROM record_label rl JOIN label l ON l.id = rl.labelId WHERE rl.recordId IN (SELECT id FROM record "+condition+") ORDER BY l.name", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer labels.Close()
+	for labels.Next() {
+		var id int64
+		var name string
+		if err := labels.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		if i, ok := index[id]; ok {
+			all[i].Labels = append(all[i].Labels, name)
+		}
+	}
+	return all, labels.Err()
+}
+
+// getRecording returns the first recording selected by the condition, or
+// ErrNotExists.
+func (r *SQLRepository) getRecording(condition string, args ...any) (*Recording, error) {
+	recordings, err := r.queryRecordings(condition, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(recordings) == 0 {
+		return nil, ErrNotExists
+	}
+	return &recordings[0], nil
+}
+
+// FindRecordings returns the recordings matching the filter, oldest first.
+// From and To select recordings overlapping [From, To).
+func (r *SQLRepository) FindRecordings(filter RecordingFilter) ([]Recording, error) {
+	condition := "WHERE 1 = 1"
+	var args []any
+	if filter.ProjectTag != "" {
+		condition += " AND projTag = ?"
+		args = append(args, filter.ProjectTag)
+	}
+	if !filter.From.IsZero() {
+		condition += " AND (endTime IS NULL OR endTime > ?)"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		condition += " AND startTime < ?"
+		args = append(args, filter.To)
+	}
+	if filter.Billable != nil {
+		condition += " AND billable = ?"
+		args = append(args, *filter.Billable)
+	}
+	if filter.Uninvoiced {
+		condition += " AND invoiceNumber IS NULL"
+	}
+	if filter.Label != "" {
+		condition += " AND id IN (SELECT recordId FROM record_label JOIN label ON label.id = record_label.labelId WHERE label.name = ?)"
+		args = append(args, filter.Label)
+	}
+	return r.queryRecordings(condition+" ORDER BY startTime", args...)
+}
+
+func (r *SQLRepository) GetProjectByTag(tag string) (*Project, error) {
+	row := r.queryRow("SELECT "+projectColumns+" FROM project WHERE tag = ?", tag)
+
+	project, err := scanProject(row)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	return project, nil
+}
+
+func (r *SQLRepository) GetRecordingsByProjectTag(tag string) ([]Recording, error) {
+	return r.queryRecordings("WHERE projTag = ? ORDER BY startTime", tag)
+}
+
+// GetRecordingsByDateRange returns all recordings overlapping [start, end),
+// including the running one.
+func (r *SQLRepository) GetRecordingsByDateRange(start, end time.Time) ([]Recording, error) {
+	return r.queryRecordings("WHERE startTime < ? AND (endTime IS NULL OR endTime > ?) ORDER BY startTime", end, start)
+}
+
+// GetRunningRecording returns the recording that has not been stopped yet.
+func (r *SQLRepository) GetRunningRecording() (*Recording, error) {
+	return r.getRecording("WHERE endTime IS NULL ORDER BY startTime DESC LIMIT 1")
 }
 
-func (r *SQLiteRepository) UpdateProject(tag string, updated Project) (*Project, error) {
+// UpdateProject changes a project. Status changes have to be allowed by
+// ProjectStatus.CanBecome, others return ErrStatusTransition.
+func (r *SQLRepository) UpdateProject(tag string, updated Project) (*Project, error) {
 	if tag == "" {
 		return nil, errors.New("invalid project tag")
 	}
-	res, err := r.db.Exec("UPDATE project SET name = ?, type = ?, status = ? WHERE tag = ?", updated.Name, updated.Type, updated.Status, tag)
+	existing, err := r.GetProjectByTag(tag)
+	if errors.Is(err, ErrNotExists) {
+		return nil, ErrUpdateFailed
+	} else if err != nil {
+		return nil, err
+	}
+	if !existing.Status.CanBecome(updated.Status) {
+		return nil, fmt.Errorf("%w from %s to %s", ErrStatusTransition, existing.Status, updated.Status)
+	}
+	res, err := r.exec("UPDATE project SET name = ?, type = ?, status = ?, hourlyRate = ?, clientId = ?, budgetHours = ?, budgetAmount = ?, budgetPeriod = ? WHERE tag = ?", updated.Name, updated.Type, updated.Status, updated.HourlyRate, nullID(updated.ClientID), updated.BudgetHours, updated.BudgetAmount, updated.BudgetPeriod, tag)
 	if err != nil {
 		return nil, err
 	}
@@ -198,8 +735,18 @@ func (r *SQLiteRepository) UpdateProject(tag string, updated Project) (*Project,
 	return &updated, nil
 }
 
-func (r *SQLiteRepository) DeleteProject(tag string) error {
-	res, err := r.db.Exec("DELETE FROM project WHERE tag = ?", tag)
+// DeleteProject deletes a project without recordings. Projects that still have
+// recordings return ErrProjectInUse.
+func (r *SQLRepository) DeleteProject(tag string) error {
+	count, err := r.CountRecordingsByProjectTag(tag)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrProjectInUse
+	}
+
+	res, err := r.exec("DELETE FROM project WHERE tag = ?", tag)
 	if err != nil {
 		return err
 	}
@@ -216,11 +763,50 @@ func (r *SQLiteRepository) DeleteProject(tag string) error {
 	return err
 }
 
-func (r *SQLiteRepository) UpdateRecording(id int64, updated Recording) (*Recording, error) {
+func (r *SQLRepository) CountRecordingsByProjectTag(tag string) (int, error) {
+	var count int
+	if err := r.queryRow("SELECT COUNT(*) FROM record WHERE projTag = ?", tag).Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ReassignRecordings moves the recordings of a project to another one.
+// Invoiced recordings stay with their project. The moved recordings lose
+// their task, which belongs to the old project.
+func (r *SQLRepository) ReassignRecordings(fromTag, toTag string) (int64, error) {
+	if _, err := r.GetProjectByTag(toTag); err != nil {
+		return 0, err
+	}
+	res, err := r.exec("UPDATE record SET projTag = ?, taskId = NULL WHERE projTag = ? AND invoiceNumber IS NULL", toTag, fromTag)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// UpdateRecording changes a recording. Invoiced recordings return
+// ErrInvoiced.
+func (r *SQLRepository) UpdateRecording(id int64, updated Recording) (*Recording, error) {
 	if id == 0 {
 		return nil, errors.New("invalid recording id")
 	}
-	res, err := r.db.Exec("UPDATE record SET projTag = ?, name = ?, startTime = ?, endTime = ?, note = ?, billable = ?, status = ? WHERE id = ?", updated.ProjectTag, updated.Name, updated.StartTime, updated.EndTime, updated.Note, updated.Billable, updated.Status, id)
+	if err := r.checkTask(updated); err != nil {
+		return nil, err
+	}
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if updated.IsRunning() {
+		if err := r.checkRunning(tx, id); err != nil {
+			return nil, err
+		}
+	}
+
+	res, err := tx.Exec(r.rebind("UPDATE record SET projTag = ?, name = ?, startTime = ?, endTime = ?, note = ?, billable = ?, status = ?, hourlyRate = ?, taskId = ? WHERE id = ? AND invoiceNumber IS NULL"), updated.ProjectTag, updated.Name, updated.StartTime, nullTime(updated.EndTime), updated.Note, updated.Billable, updated.Status, nullRate(updated.HourlyRate), nullID(updated.TaskID), id)
 	if err != nil {
 		return nil, err
 	}
@@ -231,14 +817,28 @@ func (r *SQLiteRepository) UpdateRecording(id int64, updated Recording) (*Record
 	}
 
 	if rowsAffected == 0 {
+		tx.Rollback()
+		if r.isInvoiced(id) {
+			return nil, ErrInvoiced
+		}
 		return nil, ErrUpdateFailed
 	}
+	if err := r.setLabels(tx, id, updated.Labels); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 
+	updated.ID = id
+	updated.InvoiceNumber = 0
 	return &updated, nil
 }
 
-func (r *SQLiteRepository) DeleteRecording(id int64) error {
-	res, err := r.db.Exec("DELETE FROM record WHERE id = ?", id)
+// DeleteRecording deletes a recording. Invoiced recordings return
+// ErrInvoiced.
+func (r *SQLRepository) DeleteRecording(id int64) error {
+	res, err := r.exec("DELETE FROM record WHERE id = ? AND invoiceNumber IS NULL", id)
 	if err != nil {
 		return err
 	}
@@ -249,8 +849,353 @@ func (r *SQLiteRepository) DeleteRecording(id int64) error {
 	}
 
 	if rowsAffected == 0 {
+		if r.isInvoiced(id) {
+			return ErrInvoiced
+		}
 		return ErrDeleteFailed
 	}
 
 	return err
 }
+
+// checkTask makes sure the task of a recording belongs to its project.
+func (r *SQLRepository) checkTask(recording Recording) error {
+	if recording.TaskID == 0 {
+		return nil
+	}
+	task, err := r.GetTask(recording.TaskID)
+	if err != nil {
+		return err
+	}
+	if task.ProjectTag != recording.ProjectTag {
+		return ErrTaskProject
+	}
+	return nil
+}
+
+// checkRunning returns ErrRunning if a recording other than the given one is
+// running. Only one recording may run at a time.
+func (r *SQLRepository) checkRunning(tx *sql.Tx, id int64) error {
+	var count int
+	if err := tx.QueryRow(r.rebind("SELECT COUNT(*) FROM record WHERE endTime IS NULL AND id <> ?"), id).Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrRunning
+	}
+	return nil
+}
+
+func (r *SQLRepository) isInvoiced(id int64) bool {
+	recording, err := r.GetRecordingByID(id)
+	return err == nil && recording.IsInvoiced()
+}
+
+// CreateInvoice stores an invoice under the next number and marks the
+// recordings as invoiced, all in one transaction. It fails if one of the
+// recordings is already invoiced.
+func (r *SQLRepository) CreateInvoice(invoice Invoice, recordingIDs []int64) (*Invoice, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := tx.QueryRow("SELECT COALESCE(MAX(number), 0) + 1 FROM invoice").Scan(&invoice.Number); err != nil {
+		return nil, err
+	}
+	if err := r.insertInvoice(tx, invoice); err != nil {
+		return nil, err
+	}
+	for _, id := range recordingIDs {
+		res, err := tx.Exec(r.rebind("UPDATE record SET invoiceNumber = ? WHERE id = ? AND invoiceNumber IS NULL"), invoice.Number, id)
+		if err != nil {
+			return nil, err
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return nil, err
+		} else if n == 0 {
+			return nil, fmt.Errorf("recording %d: %w", id, ErrInvoiced)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+func (r *SQLRepository) insertInvoice(tx *sql.Tx, invoice Invoice) error {
+	_, err := tx.Exec(r.rebind("INSERT INTO invoice("+invoiceColumns+") values(?,?,?,?,?,?,?)"), invoice.Number, invoice.ProjectTag, invoice.IssuedAt, invoice.From, invoice.To, invoice.Currency, invoice.Amount)
+	if err != nil {
+		return err
+	}
+	for i, line := range invoice.Lines {
+		_, err := tx.Exec(r.rebind("INSERT INTO invoice_line(invoiceNumber, position, "+invoiceLineColumns+") values(?,?,?,?,?,?,?)"), invoice.Number, i, line.Date, line.Name, line.Hours, line.Rate, line.Amount)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadInvoiceLines fills in the lines of the invoices, reading the lines
+// matching the condition.
+func (r *SQLRepository) loadInvoiceLines(invoices []Invoice, condition string, args ...any) error {
+	rows, err := r.query("SELECT invoiceNumber, "+invoiceLineColumns+" FROM invoice_line "+condition+" ORDER BY invoiceNumber, position", args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := map[int64]int{}
+	for i, invoice := range invoices {
+		index[invoice.Number] = i
+	}
+	for rows.Next() {
+		var number int64
+		var line InvoiceLine
+		if err := rows.Scan(&number, &line.Date, &line.Name, &line.Hours, &line.Rate, &line.Amount); err != nil {
+			return err
+		}
+		if i, ok := index[number]; ok {
+			invoices[i].Lines = append(invoices[i].Lines, line)
+		}
+	}
+	return rows.Err()
+}
+
+func (r *SQLRepository) AllInvoices() ([]Invoice, error) {
+	rows, err := r.query("SELECT " + invoiceColumns + " FROM invoice ORDER BY number")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var all []Invoice
+	for rows.Next() {
+		invoice, err := scanInvoice(rows)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, *invoice)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := r.loadInvoiceLines(all, ""); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+func (r *SQLRepository) GetInvoice(number int64) (*Invoice, error) {
+	invoice, err := scanInvoice(r.queryRow("SELECT "+invoiceColumns+" FROM invoice WHERE number = ?", number))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotExists
+		}
+		return nil, err
+	}
+	invoices := []Invoice{*invoice}
+	if err := r.loadInvoiceLines(invoices, "WHERE invoiceNumber = ?", number); err != nil {
+		return nil, err
+	}
+	return &invoices[0], nil
+}
+
+func (r *SQLRepository) GetRecordingsByInvoice(number int64) ([]Recording, error) {
+	return r.queryRecordings("WHERE invoiceNumber = ? ORDER BY startTime", number)
+}
+
+// Import inserts the projects and recordings in a single transaction, calling
+// progress after each recording. Nothing is stored if an insert fails.
+func (r *SQLRepository) Import(projects []Project, recordings []Recording, progress func()) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := r.insertAll(tx, projects, recordings, progress); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Restore stores backed up data in a single transaction. Clients and tasks
+// keep their ID, recordings keep theirs unless it is zero. With replace set, all existing data is deleted
+// first.
+func (r *SQLRepository) Restore(data Dataset, replace bool) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if replace {
+		for _, table := range []string{"record", "label", "invoice_line", "invoice", "task", "project", "client", "project_type"} {
+			if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+				return err
+			}
+		}
+	}
+	for _, projectType := range data.ProjectTypes {
+		if _, err := tx.Exec(r.rebind("INSERT INTO project_type("+typeColumns+") values(?,?,?)"), projectType.Name, projectType.BillableDefault, projectType.Color); err != nil {
+			return err
+		}
+	}
+	for _, client := range data.Clients {
+		if _, err := tx.Exec(r.rebind("INSERT INTO client("+clientColumns+") values(?,?,?,?,?,?)"), client.ID, client.Name, client.Address, client.VATID, client.HourlyRate, client.Currency); err != nil {
+			return err
+		}
+	}
+	if err := r.insertAll(tx, data.Projects, nil, nil); err != nil {
+		return err
+	}
+	for _, task := range data.Tasks {
+		if err := r.insertTask(tx, task); err != nil {
+			return err
+		}
+	}
+	for _, invoice := range data.Invoices {
+		if err := r.insertInvoice(tx, invoice); err != nil {
+			return err
+		}
+	}
+	if err := r.insertAll(tx, nil, data.Recordings, nil); err != nil {
+		return err
+	}
+	if r.dialect == dialectPostgres {
+		// explicit ids don't advance the sequences
+		for _, table := range []string{"record", "client", "task"} {
+			if _, err := tx.Exec("SELECT setval(pg_get_serial_sequence('" + table + "', 'id'), COALESCE((SELECT MAX(id) FROM " + table + "), 0) + 1, false)"); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// insertAll stores projects and recordings. Imported projects may bring
+// types that don't exist yet, they are created without defaults. It returns
+// ErrRunning if more than one recording would be running.
+func (r *SQLRepository) insertAll(tx *sql.Tx, projects []Project, recordings []Recording, progress func()) error {
+	for _, project := range projects {
+		if _, err := tx.Exec(r.rebind("INSERT INTO project_type(name) values(?) ON CONFLICT(name) DO NOTHING"), project.Type); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(r.rebind("INSERT INTO project("+projectColumns+") values(?,?,?,?,?,?,?,?,?)"), project.Tag, project.Name, project.Type, project.Status, project.HourlyRate, nullID(project.ClientID), project.BudgetHours, project.BudgetAmount, project.BudgetPeriod); err != nil {
+			return err
+		}
+	}
+	for _, recording := range recordings {
+		if err := r.insertRecording(tx, &recording); err != nil {
+			return err
+		}
+		if progress != nil {
+			progress()
+		}
+	}
+	if len(recordings) > 0 {
+		var running int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM record WHERE endTime IS NULL").Scan(&running); err != nil {
+			return err
+		}
+		if running > 1 {
+			return ErrRunning
+		}
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanProject(row scanner) (*Project, error) {
+	var project Project
+	var clientID sql.NullInt64
+	if err := row.Scan(&project.Tag, &project.Name, &project.Type, &project.Status, &project.HourlyRate, &clientID, &project.BudgetHours, &project.BudgetAmount, &project.BudgetPeriod); err != nil {
+		return nil, err
+	}
+	project.ClientID = clientID.Int64
+	return &project, nil
+}
+
+func scanProjectType(row scanner) (*ProjectType, error) {
+	var projectType ProjectType
+	if err := row.Scan(&projectType.Name, &projectType.BillableDefault, &projectType.Color); err != nil {
+		return nil, err
+	}
+	return &projectType, nil
+}
+
+func scanClient(row scanner) (*Client, error) {
+	var client Client
+	if err := row.Scan(&client.ID, &client.Name, &client.Address, &client.VATID, &client.HourlyRate, &client.Currency); err != nil {
+		return nil, err
+	}
+	return &client, nil
+}
+
+// scanRecording reads a record row, leaving EndTime zero for running recordings.
+func scanRecording(row scanner) (*Recording, error) {
+	var recording Recording
+	var endTime sql.NullTime
+	var hourlyRate sql.NullFloat64
+	var invoiceNumber, taskID sql.NullInt64
+	if err := row.Scan(&recording.ID, &recording.ProjectTag, &recording.StartTime, &endTime, &recording.Name, &recording.Billable, &recording.Note, &recording.Status, &hourlyRate, &invoiceNumber, &taskID); err != nil {
+		return nil, err
+	}
+	recording.EndTime = endTime.Time
+	recording.HourlyRate = hourlyRate.Float64
+	recording.InvoiceNumber = invoiceNumber.Int64
+	recording.TaskID = taskID.Int64
+	return &recording, nil
+}
+
+// scanTask reads a task row; estimates are stored in minutes.
+func scanTask(row scanner) (*Task, error) {
+	var task Task
+	var estimate int64
+	if err := row.Scan(&task.ID, &task.ProjectTag, &task.Name, &estimate, &task.Done); err != nil {
+		return nil, err
+	}
+	task.Estimate = time.Duration(estimate) * time.Minute
+	return &task, nil
+}
+
+func scanInvoice(row scanner) (*Invoice, error) {
+	var invoice Invoice
+	if err := row.Scan(&invoice.Number, &invoice.ProjectTag, &invoice.IssuedAt, &invoice.From, &invoice.To, &invoice.Currency, &invoice.Amount); err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// nullTime stores a zero time as NULL, which marks a recording as running.
+func nullTime(t time.Time) sql.NullTime {
+	return sql.NullTime{Time: t, Valid: !t.IsZero()}
+}
+
+// nullRate stores a zero rate as NULL, which means the project's rate applies.
+func nullRate(rate float64) sql.NullFloat64 {
+	return sql.NullFloat64{Float64: rate, Valid: rate != 0}
+}
+
+// nullID stores a missing reference, like the invoice of a recording that is
+// not invoiced yet, as NULL.
+func nullID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+// isUniqueViolation reports whether an insert or update failed on a primary
+// key or unique constraint, in SQLite as well as in PostgreSQL.
+func isUniqueViolation(err error) bool {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,345 @@
+package domain
+
+import (
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// backends are the repositories the contract tests run against.
+var backends = []struct {
+	name string
+	open func(t *testing.T) Repository
+}{
+	{"memory", func(t *testing.T) Repository {
+		return NewMemoryRepository()
+	}},
+	{"sqlite", func(t *testing.T) Repository {
+		db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db")+"?_pragma=foreign_keys(1)")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { db.Close() })
+		repo := NewSQLiteRepository(db)
+		if err := repo.Migrate(); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	}},
+}
+
+func TestRepository(t *testing.T) {
+	contract := []struct {
+		name string
+		test func(t *testing.T, repo Repository)
+	}{
+		{"duplicate project", testDuplicateProject},
+		{"one running recording", testOneRunningRecording},
+		{"find recordings", testFindRecordings},
+		{"invoiced recordings", testInvoicedRecordings},
+		{"reassign and delete", testReassignAndDelete},
+		{"project status", testProjectStatus},
+		{"restore running recording", testRestoreRunningRecording},
+	}
+	for _, backend := range backends {
+		for _, c := range contract {
+			t.Run(backend.name+"/"+c.name, func(t *testing.T) {
+				c.test(t, backend.open(t))
+			})
+		}
+	}
+}
+
+// day is 2024-03-11 in local time, the times recordings are stored in.
+var day = time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+
+func at(hours float64) time.Time {
+	return day.Add(time.Duration(hours * float64(time.Hour)))
+}
+
+func mustCreateProject(t *testing.T, repo Repository, project Project) {
+	t.Helper()
+	if project.Type == "" {
+		project.Type = "customer"
+	}
+	if _, err := repo.CreateProject(project); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustCreateRecording(t *testing.T, repo Repository, recording Recording) *Recording {
+	t.Helper()
+	created, err := repo.CreateRecording(recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created
+}
+
+func ids(recordings []Recording) []int64 {
+	var ids []int64
+	for _, recording := range recordings {
+		ids = append(ids, recording.ID)
+	}
+	return ids
+}
+
+func equalIDs(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func testDuplicateProject(t *testing.T, repo Repository) {
+	mustCreateProject(t, repo, Project{Tag: "DAG", Name: "DAG"})
+	if _, err := repo.CreateProject(Project{Tag: "DAG", Name: "again", Type: "other"}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("got %v, want ErrDuplicate", err)
+	}
+}
+
+func testOneRunningRecording(t *testing.T, repo Repository) {
+	mustCreateProject(t, repo, Project{Tag: "A", Name: "a"})
+	running := mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(9), Name: "running"})
+	stopped := mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(7), EndTime: at(8), Name: "stopped"})
+
+	if _, err := repo.CreateRecording(Recording{ProjectTag: "A", StartTime: at(10), Name: "second"}); !errors.Is(err, ErrRunning) {
+		t.Errorf("second running recording: got %v, want ErrRunning", err)
+	}
+	restarted := *stopped
+	restarted.EndTime = time.Time{}
+	if _, err := repo.UpdateRecording(stopped.ID, restarted); !errors.Is(err, ErrRunning) {
+		t.Errorf("restarting a stopped recording: got %v, want ErrRunning", err)
+	}
+	// the running recording itself may be changed
+	renamed := *running
+	renamed.Name = "renamed"
+	if _, err := repo.UpdateRecording(running.ID, renamed); err != nil {
+		t.Errorf("renaming the running recording: %v", err)
+	}
+
+	got, err := repo.GetRunningRecording()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != running.ID {
+		t.Errorf("running recording is %d, want %d", got.ID, running.ID)
+	}
+
+	renamed.EndTime = at(11)
+	if _, err := repo.UpdateRecording(running.ID, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.GetRunningRecording(); !errors.Is(err, ErrNotExists) {
+		t.Errorf("after stopping: got %v, want ErrNotExists", err)
+	}
+	mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(12), Name: "next"})
+}
+
+func testFindRecordings(t *testing.T, repo Repository) {
+	mustCreateProject(t, repo, Project{Tag: "A", Name: "a"})
+	mustCreateProject(t, repo, Project{Tag: "B", Name: "b"})
+	billable := true
+	morning := mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(8), EndTime: at(9), Name: "morning", Billable: true})
+	late := mustCreateRecording(t, repo, Recording{ProjectTag: "B", StartTime: at(9), EndTime: at(10), Name: "late", Labels: []string{"meeting"}})
+	overnight := mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(23), EndTime: at(25), Name: "overnight"})
+	running := mustCreateRecording(t, repo, Recording{ProjectTag: "B", StartTime: at(36), Name: "running"})
+
+	tests := []struct {
+		name   string
+		filter RecordingFilter
+		want   []int64
+	}{
+		{"all, oldest first", RecordingFilter{}, []int64{morning.ID, late.ID, overnight.ID, running.ID}},
+		// recordings touching the range at its edges don't overlap it
+		{"exact hour", RecordingFilter{From: at(9), To: at(10)}, []int64{late.ID}},
+		{"first day", RecordingFilter{From: at(0), To: at(24)}, []int64{morning.ID, late.ID, overnight.ID}},
+		{"second day with running", RecordingFilter{From: at(24), To: at(48)}, []int64{overnight.ID, running.ID}},
+		{"only from", RecordingFilter{From: at(30)}, []int64{running.ID}},
+		{"only to", RecordingFilter{To: at(9)}, []int64{morning.ID}},
+		{"project", RecordingFilter{ProjectTag: "A"}, []int64{morning.ID, overnight.ID}},
+		{"billable", RecordingFilter{Billable: &billable}, []int64{morning.ID}},
+		{"label", RecordingFilter{Label: "meeting"}, []int64{late.ID}},
+	}
+	for _, test := range tests {
+		found, err := repo.FindRecordings(test.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !equalIDs(ids(found), test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, ids(found), test.want)
+		}
+	}
+
+	found, err := repo.GetRecordingsByDateRange(at(24), at(48))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{overnight.ID, running.ID}; !equalIDs(ids(found), want) {
+		t.Errorf("date range: got %v, want %v", ids(found), want)
+	}
+}
+
+func testInvoicedRecordings(t *testing.T, repo Repository) {
+	mustCreateProject(t, repo, Project{Tag: "A", Name: "a", HourlyRate: 100})
+	billed := mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(8), EndTime: at(10), Name: "billed", Billable: true})
+	open := mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(11), EndTime: at(12), Name: "open", Billable: true})
+
+	lines := []InvoiceLine{{Date: at(8), Name: "billed", Hours: 2, Rate: 100, Amount: 200}}
+	invoice, err := repo.CreateInvoice(Invoice{ProjectTag: "A", IssuedAt: at(13), From: at(0), To: at(24), Currency: "EUR", Amount: 200, Lines: lines}, []int64{billed.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changed := *billed
+	changed.Name = "changed"
+	if _, err := repo.UpdateRecording(billed.ID, changed); !errors.Is(err, ErrInvoiced) {
+		t.Errorf("update: got %v, want ErrInvoiced", err)
+	}
+	if err := repo.DeleteRecording(billed.ID); !errors.Is(err, ErrInvoiced) {
+		t.Errorf("delete: got %v, want ErrInvoiced", err)
+	}
+	if _, err := repo.CreateInvoice(Invoice{ProjectTag: "A", IssuedAt: at(14), From: at(0), To: at(24), Currency: "EUR"}, []int64{open.ID, billed.ID}); !errors.Is(err, ErrInvoiced) {
+		t.Errorf("billing twice: got %v, want ErrInvoiced", err)
+	}
+	// the failed invoice must not bill the other recording
+	uninvoiced, err := repo.FindRecordings(RecordingFilter{Uninvoiced: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{open.ID}; !equalIDs(ids(uninvoiced), want) {
+		t.Errorf("uninvoiced: got %v, want %v", ids(uninvoiced), want)
+	}
+
+	stored, err := repo.GetInvoice(invoice.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stored.Lines) != 1 || stored.Lines[0].Amount != 200 || !stored.Lines[0].Date.Equal(at(8)) {
+		t.Errorf("stored lines %+v, want %+v", stored.Lines, lines)
+	}
+	invoiced, err := repo.GetRecordingsByInvoice(invoice.Number)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{billed.ID}; !equalIDs(ids(invoiced), want) {
+		t.Errorf("invoiced recordings: got %v, want %v", ids(invoiced), want)
+	}
+}
+
+func testReassignAndDelete(t *testing.T, repo Repository) {
+	mustCreateProject(t, repo, Project{Tag: "A", Name: "a"})
+	mustCreateProject(t, repo, Project{Tag: "B", Name: "b"})
+	mustCreateProject(t, repo, Project{Tag: "EMPTY", Name: "empty"})
+	billed := mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(8), EndTime: at(9), Name: "billed"})
+	mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(10), EndTime: at(11), Name: "open"})
+	if _, err := repo.CreateInvoice(Invoice{ProjectTag: "A", IssuedAt: at(12), From: at(0), To: at(24), Currency: "EUR"}, []int64{billed.ID}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := repo.DeleteProject("A"); !errors.Is(err, ErrProjectInUse) {
+		t.Errorf("delete with recordings: got %v, want ErrProjectInUse", err)
+	}
+	if _, err := repo.ReassignRecordings("A", "MISSING"); !errors.Is(err, ErrNotExists) {
+		t.Errorf("reassign to a missing project: got %v, want ErrNotExists", err)
+	}
+	moved, err := repo.ReassignRecordings("A", "B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 {
+		t.Errorf("moved %d recordings, want 1", moved)
+	}
+	// invoiced recordings stay with their project and keep it
+	if count, err := repo.CountRecordingsByProjectTag("A"); err != nil || count != 1 {
+		t.Errorf("left %d recordings (%v), want 1", count, err)
+	}
+	if err := repo.DeleteProject("A"); !errors.Is(err, ErrProjectInUse) {
+		t.Errorf("delete with invoiced recordings: got %v, want ErrProjectInUse", err)
+	}
+
+	if err := repo.DeleteProject("EMPTY"); err != nil {
+		t.Errorf("delete without recordings: %v", err)
+	}
+	if err := repo.DeleteProject("EMPTY"); !errors.Is(err, ErrDeleteFailed) {
+		t.Errorf("delete twice: got %v, want ErrDeleteFailed", err)
+	}
+}
+
+func testProjectStatus(t *testing.T, repo Repository) {
+	if _, err := repo.CreateProject(Project{Tag: "BAD", Name: "bad", Type: "other", Status: 42}); err == nil {
+		t.Error("created a project with an unknown status")
+	}
+	if _, err := repo.UpdateProject("MISSING", Project{Name: "missing", Type: "other"}); !errors.Is(err, ErrUpdateFailed) {
+		t.Errorf("update missing: got %v, want ErrUpdateFailed", err)
+	}
+
+	for i, from := range ProjectStatuses {
+		for j, to := range ProjectStatuses {
+			tag := string(rune('A'+i)) + string(rune('A'+j))
+			mustCreateProject(t, repo, Project{Tag: tag, Name: tag, Status: from})
+			_, err := repo.UpdateProject(tag, Project{Tag: tag, Name: tag, Type: "customer", Status: to})
+			if from.CanBecome(to) && err != nil {
+				t.Errorf("%s to %s: %v", from, to, err)
+			} else if !from.CanBecome(to) && !errors.Is(err, ErrStatusTransition) {
+				t.Errorf("%s to %s: got %v, want ErrStatusTransition", from, to, err)
+			}
+
+			stored, err := repo.GetProjectByTag(tag)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := from
+			if from.CanBecome(to) {
+				want = to
+			}
+			if stored.Status != want {
+				t.Errorf("%s to %s: stored %s", from, to, stored.Status)
+			}
+		}
+	}
+
+	active, err := repo.AllActiveProjects()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, project := range active {
+		if project.Status != StatusActive {
+			t.Errorf("active projects include %s, which is %s", project.Tag, project.Status)
+		}
+	}
+}
+
+func testRestoreRunningRecording(t *testing.T, repo Repository) {
+	mustCreateProject(t, repo, Project{Tag: "A", Name: "a"})
+	mustCreateRecording(t, repo, Recording{ProjectTag: "A", StartTime: at(9), Name: "running"})
+
+	data := Dataset{Recordings: []Recording{
+		{ID: 100, ProjectTag: "A", StartTime: at(7), EndTime: at(8), Name: "stopped"},
+		{ID: 101, ProjectTag: "A", StartTime: at(10), Name: "second"},
+	}}
+	if err := repo.Restore(data, false); !errors.Is(err, ErrRunning) {
+		t.Errorf("merging a second running recording: got %v, want ErrRunning", err)
+	}
+	recordings, err := repo.AllRecordings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 1 {
+		t.Errorf("got %d recordings after the failed restore, want 1", len(recordings))
+	}
+
+	data.Recordings[1].EndTime = at(11)
+	if err := repo.Restore(data, false); err != nil {
+		t.Errorf("merging stopped recordings: %v", err)
+	}
+}
@@ -0,0 +1,329 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/utils"
+)
+
+// CSVColumns are the columns WriteCSV knows, in their default order.
+var CSVColumns = []string{"id", "date", "start", "end", "duration", "tag", "project", "type", "name", "billable", "note", "labels"}
+
+const (
+	DurationDecimal = "decimal"
+	DurationHHMM    = "hhmm"
+)
+
+type CSVOptions struct {
+	Columns   []string
+	Delimiter rune
+	// Duration is DurationDecimal (1.50) or DurationHHMM (01:30).
+	Duration string
+}
+
+// Validate reports unknown columns and duration formats.
+func (opts CSVOptions) Validate() error {
+	for _, column := range opts.Columns {
+		if !validColumn(column) {
+			return fmt.Errorf("unknown column %q", column)
+		}
+	}
+	if opts.Duration != DurationDecimal && opts.Duration != DurationHHMM {
+		return fmt.Errorf("unknown duration format %q", opts.Duration)
+	}
+	return nil
+}
+
+// WriteCSV writes one line per row with a header line. Running recordings
+// have an empty end.
+func WriteCSV(w io.Writer, rows []Row, opts CSVOptions) error {
+	if len(opts.Columns) == 0 {
+		opts.Columns = CSVColumns
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(w)
+	if opts.Delimiter != 0 {
+		writer.Comma = opts.Delimiter
+	}
+	if err := writer.Write(opts.Columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, 0, len(opts.Columns))
+		for _, column := range opts.Columns {
+			record = append(record, csvValue(row, column, opts.Duration))
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func validColumn(column string) bool {
+	for _, c := range CSVColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+func csvValue(row Row, column string, duration string) string {
+	switch column {
+	case "id":
+		return strconv.FormatInt(row.ID, 10)
+	case "date":
+		return row.StartTime.Format(utils.DateLayout)
+	case "start":
+		return row.StartTime.Format(utils.DateTimeLayout)
+	case "end":
+		if row.IsRunning() {
+			return ""
+		}
+		return row.EndTime.Format(utils.DateTimeLayout)
+	case "duration":
+		if duration == DurationHHMM {
+			return utils.FormatDuration(row.Duration())
+		}
+		return strconv.FormatFloat(row.Duration().Hours(), 'f', 2, 64)
+	case "tag":
+		return row.ProjectTag
+	case "project":
+		return row.Project.Name
+	case "type":
+		return row.Project.Type
+	case "name":
+		return row.Name
+	case "billable":
+		return strconv.FormatBool(row.Billable)
+	case "note":
+		return row.Note
+	case "labels":
+		return strings.Join(row.Labels, " ")
+	}
+	return ""
+}
+
+// CSVFields are the recording fields ReadCSV can map columns to. Either end
+// or duration is required. With a date column, start and end may be times of
+// day.
+var CSVFields = []string{"tag", "project", "type", "date", "start", "end", "duration", "name", "billable", "note"}
+
+type CSVImportOptions struct {
+	// Mapping maps fields to column headers. Unmapped fields are read from
+	// the column named like the field, if there is one.
+	Mapping   map[string]string
+	Delimiter rune
+}
+
+// ReadCSV parses recordings from a CSV file with a header line. Lines that
+// can't be parsed are returned with Err set.
+func ReadCSV(r io.Reader, opts CSVImportOptions) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	if opts.Delimiter != 0 {
+		reader.Comma = opts.Delimiter
+	}
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	index := map[string]int{}
+	for field, column := range opts.Mapping {
+		if !validField(field) {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		i := columnIndex(header, column)
+		if i < 0 {
+			return nil, fmt.Errorf("column %q not found", column)
+		}
+		index[field] = i
+	}
+	for _, field := range CSVFields {
+		if _, ok := index[field]; !ok {
+			if i := columnIndex(header, field); i >= 0 {
+				index[field] = i
+			}
+		}
+	}
+	for _, field := range []string{"tag", "start"} {
+		if _, ok := index[field]; !ok {
+			return nil, fmt.Errorf("no column for field %q", field)
+		}
+	}
+	_, hasEnd := index["end"]
+	_, hasDuration := index["duration"]
+	if !hasEnd && !hasDuration {
+		return nil, errors.New("no column for field \"end\" or \"duration\"")
+	}
+
+	var rows []ImportRow
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(field string) string {
+			i, ok := index[field]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+		row, err := parseCSVRow(value)
+		rows = append(rows, ImportRow{Line: line, Row: row, Err: err})
+	}
+	return rows, nil
+}
+
+func parseCSVRow(value func(field string) string) (Row, error) {
+	row := Row{}
+	row.ProjectTag = value("tag")
+	row.Project = domain.Project{Tag: row.ProjectTag, Name: value("project"), Type: value("type")}
+	row.Name = value("name")
+	row.Note = value("note")
+	if row.ProjectTag == "" {
+		return row, errors.New("missing tag")
+	}
+
+	var err error
+	if date := value("date"); date != "" {
+		day, err := parseDate(date)
+		if err != nil {
+			return row, err
+		}
+		if row.StartTime, err = parseTimeOnDay(day, value("start")); err != nil {
+			return row, err
+		}
+		if end := value("end"); end != "" {
+			if row.EndTime, err = parseTimeOnDay(day, end); err != nil {
+				return row, err
+			}
+			// an end time before the start time is on the next day
+			if !row.EndTime.After(row.StartTime) && len(end) <= len("15:04:05") {
+				row.EndTime = row.EndTime.AddDate(0, 0, 1)
+			}
+		}
+	} else {
+		if row.StartTime, err = parseDateTime(value("start")); err != nil {
+			return row, err
+		}
+		if end := value("end"); end != "" {
+			if row.EndTime, err = parseDateTime(end); err != nil {
+				return row, err
+			}
+		}
+	}
+	if row.EndTime.IsZero() {
+		duration, err := parseDuration(value("duration"))
+		if err != nil {
+			return row, err
+		}
+		row.EndTime = row.StartTime.Add(duration)
+	}
+	if !row.EndTime.After(row.StartTime) {
+		return row, errors.New("end is not after start")
+	}
+
+	if row.Billable, err = parseBool(value("billable")); err != nil {
+		return row, err
+	}
+	return row, nil
+}
+
+func validField(field string) bool {
+	for _, f := range CSVFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func columnIndex(header []string, column string) int {
+	for i, h := range header {
+		if strings.EqualFold(strings.TrimSpace(h), column) {
+			return i
+		}
+	}
+	return -1
+}
+
+var (
+	dateLayouts     = []string{utils.DateLayout, "02.01.2006", "01/02/2006"}
+	timeLayouts     = []string{"15:04", "15:04:05"}
+	dateTimeLayouts = []string{utils.DateTimeLayout, "2006-01-02 15:04:05", time.RFC3339, "2006-01-02T15:04", "2006-01-02T15:04:05", "02.01.2006 15:04", "02.01.2006 15:04:05"}
+)
+
+func parseWithLayouts(s string, layouts []string, what string) (time.Time, error) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			// times with an offset are stored in local time like all others
+			return t.Local(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid %s %q", what, s)
+}
+
+func parseDate(s string) (time.Time, error) {
+	return parseWithLayouts(s, dateLayouts, "date")
+}
+
+func parseDateTime(s string) (time.Time, error) {
+	return parseWithLayouts(s, dateTimeLayouts, "time")
+}
+
+// parseTimeOnDay reads a time of day on the given day, or a full date and
+// time.
+func parseTimeOnDay(day time.Time, s string) (time.Time, error) {
+	t, err := parseWithLayouts(s, timeLayouts, "time")
+	if err != nil {
+		return parseDateTime(s)
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.Local), nil
+}
+
+// parseDuration reads decimal hours ("1.5" or "1,5") or hours and minutes
+// ("01:30").
+func parseDuration(s string) (time.Duration, error) {
+	if h, m, ok := strings.Cut(s, ":"); ok {
+		hours, err1 := strconv.Atoi(h)
+		minutes, err2 := strconv.Atoi(m)
+		if err1 != nil || err2 != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+	}
+	hours, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return time.Duration(hours * float64(time.Hour)).Round(time.Second), nil
+}
+
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "", "false", "no", "n", "0", "nein":
+		return false, nil
+	case "true", "yes", "y", "1", "x", "ja":
+		return true, nil
+	}
+	return false, fmt.Errorf("invalid billable value %q", s)
+}
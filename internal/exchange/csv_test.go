@@ -0,0 +1,83 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const csvFixture = `Tag;Date;Start;End;Duration;Name;Billable
+DAG;2024-03-11;09:00;10:30;;review;yes
+DAG;11.03.2024;23:00;01:00;;overnight;
+WEB;;2024-03-11T09:00:00+02:00;;01:30;offset;x
+WEB;2024-03-11;09:00;;1,5;decimal;no
+;2024-03-11;09:00;10:00;;no tag;
+DAG;2024-13-01;09:00;10:00;;bad date;
+DAG;2024-03-11;10:00;2024-03-11 09:00;;backwards;
+DAG;2024-03-11;09:00;;abc;bad duration;
+DAG;2024-03-11;09:00;10:00;;bad billable;maybe
+`
+
+func TestReadCSV(t *testing.T) {
+	rows, err := ReadCSV(strings.NewReader(csvFixture), CSVImportOptions{Delimiter: ';'})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 9 {
+		t.Fatalf("got %d rows, want 9", len(rows))
+	}
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+	tests := []struct {
+		start, end time.Time
+		billable   bool
+	}{
+		{day.Add(9 * time.Hour), day.Add(10*time.Hour + 30*time.Minute), true},
+		{day.Add(23 * time.Hour), day.Add(25 * time.Hour), false},
+		{time.Date(2024, 3, 11, 7, 0, 0, 0, time.UTC), time.Date(2024, 3, 11, 8, 30, 0, 0, time.UTC), true},
+		{day.Add(9 * time.Hour), day.Add(10*time.Hour + 30*time.Minute), false},
+	}
+	for i, test := range tests {
+		row := rows[i]
+		if row.Err != nil {
+			t.Errorf("line %d: %v", row.Line, row.Err)
+			continue
+		}
+		if !row.StartTime.Equal(test.start) || !row.EndTime.Equal(test.end) {
+			t.Errorf("line %d: %v - %v, want %v - %v", row.Line, row.StartTime, row.EndTime, test.start, test.end)
+		}
+		if row.StartTime.Location() != time.Local || row.EndTime.Location() != time.Local {
+			t.Errorf("line %d: times in %v, want local time", row.Line, row.StartTime.Location())
+		}
+		if row.Billable != test.billable {
+			t.Errorf("line %d: billable %v, want %v", row.Line, row.Billable, test.billable)
+		}
+	}
+	for _, row := range rows[len(tests):] {
+		if row.Err == nil {
+			t.Errorf("line %d (%s): no error", row.Line, row.Name)
+		}
+	}
+}
+
+func TestReadCSVMapping(t *testing.T) {
+	input := "Projekt,Beginn,Stunden\nDAG,2024-03-11 09:00,2\n"
+	rows, err := ReadCSV(strings.NewReader(input), CSVImportOptions{
+		Mapping: map[string]string{"tag": "Projekt", "start": "Beginn", "duration": "Stunden"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Err != nil || rows[0].ProjectTag != "DAG" || rows[0].Duration() != 2*time.Hour {
+		t.Errorf("got %+v", rows)
+	}
+
+	for _, opts := range []CSVImportOptions{
+		{Mapping: map[string]string{"color": "Projekt"}},
+		{Mapping: map[string]string{"tag": "Kunde"}},
+		{Mapping: map[string]string{"tag": "Projekt", "start": "Beginn"}},
+	} {
+		if _, err := ReadCSV(strings.NewReader(input), opts); err == nil {
+			t.Errorf("mapping %v: no error", opts.Mapping)
+		}
+	}
+}
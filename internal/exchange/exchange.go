@@ -0,0 +1,31 @@
+// Package exchange reads and writes recordings in the formats of other tools.
+package exchange
+
+import (
+	"downardo.at/timetracking/internal/domain"
+)
+
+// Row is a recording joined with its project.
+type Row struct {
+	domain.Recording
+	Project domain.Project
+}
+
+// Join looks up the project of every recording. Recordings of unknown
+// projects get a project carrying only the tag.
+func Join(recordings []domain.Recording, projects []domain.Project) []Row {
+	byTag := map[string]domain.Project{}
+	for _, project := range projects {
+		byTag[project.Tag] = project
+	}
+
+	rows := make([]Row, 0, len(recordings))
+	for _, recording := range recordings {
+		project, ok := byTag[recording.ProjectTag]
+		if !ok {
+			project = domain.Project{Tag: recording.ProjectTag}
+		}
+		rows = append(rows, Row{Recording: recording, Project: project})
+	}
+	return rows
+}
@@ -0,0 +1,477 @@
+package exchange
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// icsLayout is the UTC date-time form of iCalendar, which calendars show in
+// their own time zone.
+const icsLayout = "20060102T150405Z"
+
+// ICSUID is the stable UID of the event of a recording.
+func ICSUID(id int64) string {
+	return fmt.Sprintf("recording-%d@timetracking", id)
+}
+
+// WriteICS writes the rows as an iCalendar file with one event per
+// recording.
+func WriteICS(w io.Writer, rows []Row) error {
+	stamp := time.Now().UTC().Format(icsLayout)
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//downardo.at//timetracking//EN",
+		"CALSCALE:GREGORIAN",
+	}
+	for _, row := range rows {
+		summary := row.ProjectTag
+		if row.Name != "" {
+			summary += ": " + row.Name
+		}
+		lines = append(lines,
+			"BEGIN:VEVENT",
+			"UID:"+ICSUID(row.ID),
+			"DTSTAMP:"+stamp,
+			"DTSTART:"+row.StartTime.UTC().Format(icsLayout),
+			"DTEND:"+row.EndTime.UTC().Format(icsLayout),
+			"SUMMARY:"+icsEscape(summary),
+			"CATEGORIES:"+icsEscape(row.ProjectTag),
+		)
+		if row.Note != "" {
+			lines = append(lines, "DESCRIPTION:"+icsEscape(row.Note))
+		}
+		lines = append(lines, "TRANSP:TRANSPARENT", "END:VEVENT")
+	}
+	lines = append(lines, "END:VCALENDAR")
+
+	for _, line := range lines {
+		if _, err := io.WriteString(w, icsFold(line)+"\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func icsEscape(s string) string {
+	return icsEscaper.Replace(s)
+}
+
+// icsFold splits lines longer than 75 octets, continuing them on lines
+// starting with a space, without splitting characters.
+func icsFold(line string) string {
+	var folded strings.Builder
+	limit := 75
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+		// the leading space counts towards the limit
+		limit = 74
+	}
+	folded.WriteString(line)
+	return folded.String()
+}
+
+// ICSEvent is an event read from an iCalendar file.
+type ICSEvent struct {
+	UID         string
+	Summary     string
+	Description string
+	Organizer   string
+	Start       time.Time
+	End         time.Time
+	// AllDay events have dates only and are not imported.
+	AllDay bool
+	// Recurrence is the RRULE of a recurring event, empty for single events.
+	Recurrence string
+	// Exceptions are the starts of occurrences removed by EXDATE, cancelled
+	// or replaced by an event of their own.
+	Exceptions []time.Time
+	// RecurrenceID is the start of the occurrence this event replaces.
+	RecurrenceID time.Time
+}
+
+// ReadICS reads the events of an iCalendar file. Cancelled events are left
+// out, recurring events are returned once and expanded by Occurrences.
+// Times keep the zone given in the file.
+// Components nested in events, like alarms, are skipped.
+func ReadICS(r io.Reader) ([]ICSEvent, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	// unfold continuation lines
+	text = strings.ReplaceAll(text, "\n ", "")
+	text = strings.ReplaceAll(text, "\n\t", "")
+
+	var events []ICSEvent
+	// cancelled occurrences still remove their date from the series
+	var cancelled []ICSEvent
+	var event *ICSEvent
+	var duration time.Duration
+	var isCancelled bool
+	// depth counts the components open inside the event
+	depth := 0
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimRight(line, "\r")
+		nameAndParams, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		params := strings.Split(nameAndParams, ";")
+		name := strings.ToUpper(params[0])
+
+		switch {
+		case name == "BEGIN" && value == "VEVENT" && event == nil:
+			event = &ICSEvent{}
+			duration = 0
+			isCancelled = false
+			depth = 0
+			continue
+		case event == nil:
+			continue
+		case name == "BEGIN":
+			depth++
+			continue
+		case name == "END" && depth > 0:
+			depth--
+			continue
+		case name == "END" && value == "VEVENT":
+			if event.End.IsZero() {
+				event.End = event.Start.Add(duration)
+				if event.AllDay && duration == 0 {
+					event.End = event.Start.AddDate(0, 0, 1)
+				}
+			}
+			if isCancelled {
+				cancelled = append(cancelled, *event)
+			} else {
+				events = append(events, *event)
+			}
+			event = nil
+			continue
+		case depth > 0:
+			continue
+		}
+
+		switch name {
+		case "UID":
+			event.UID = value
+		case "SUMMARY":
+			event.Summary = icsUnescape(value)
+		case "DESCRIPTION":
+			event.Description = icsUnescape(value)
+		case "ORGANIZER":
+			event.Organizer = strings.TrimPrefix(strings.TrimPrefix(value, "mailto:"), "MAILTO:")
+		case "STATUS":
+			isCancelled = strings.EqualFold(value, "CANCELLED")
+		case "DTSTART", "DTEND", "RECURRENCE-ID":
+			t, allDay, err := parseICSTime(value, params[1:])
+			if err != nil {
+				return nil, fmt.Errorf("event %q: %w", event.Summary, err)
+			}
+			switch name {
+			case "DTSTART":
+				event.Start = t
+				event.AllDay = allDay
+			case "DTEND":
+				event.End = t
+			default:
+				event.RecurrenceID = t
+			}
+		case "DURATION":
+			if duration, err = parseICSDuration(value); err != nil {
+				return nil, fmt.Errorf("event %q: %w", event.Summary, err)
+			}
+		case "RRULE":
+			event.Recurrence = value
+		case "EXDATE":
+			for _, date := range strings.Split(value, ",") {
+				t, _, err := parseICSTime(date, params[1:])
+				if err != nil {
+					return nil, fmt.Errorf("event %q: %w", event.Summary, err)
+				}
+				event.Exceptions = append(event.Exceptions, t)
+			}
+		}
+	}
+
+	// occurrences replaced by an event of their own or cancelled are no
+	// longer part of their series
+	for _, replacement := range append(slices.Clip(events), cancelled...) {
+		if replacement.RecurrenceID.IsZero() {
+			continue
+		}
+		for i := range events {
+			if events[i].UID == replacement.UID && events[i].Recurrence != "" {
+				events[i].Exceptions = append(events[i].Exceptions, replacement.RecurrenceID)
+			}
+		}
+	}
+	return events, nil
+}
+
+// Occurrences returns the occurrences of the event overlapping [from, to) in
+// local time, the event itself if it does not recur. Recurrence rules are supported with
+// FREQ, INTERVAL, COUNT, UNTIL and, for daily and weekly events, BYDAY with
+// plain weekdays. Other rules return an error.
+func (e ICSEvent) Occurrences(from, to time.Time) ([]ICSEvent, error) {
+	overlaps := func(event ICSEvent) bool {
+		return event.Start.Before(to) && event.End.After(from)
+	}
+	if e.Recurrence == "" {
+		if overlaps(e) {
+			e.Start, e.End = e.Start.Local(), e.End.Local()
+			return []ICSEvent{e}, nil
+		}
+		return nil, nil
+	}
+
+	rule, err := parseRRule(e.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+	length := e.End.Sub(e.Start)
+	var occurrences []ICSEvent
+	count := 0
+	for _, start := range rule.starts(e.Start, to) {
+		if !rule.until.IsZero() && start.After(rule.until) {
+			break
+		}
+		count++
+		if rule.count > 0 && count > rule.count {
+			break
+		}
+		if slices.ContainsFunc(e.Exceptions, start.Equal) {
+			continue
+		}
+		occurrence := e
+		occurrence.Start = start.Local()
+		occurrence.End = start.Add(length).Local()
+		occurrence.Recurrence = ""
+		occurrence.Exceptions = nil
+		if overlaps(occurrence) {
+			occurrences = append(occurrences, occurrence)
+		}
+	}
+	return occurrences, nil
+}
+
+// rrule is the supported part of an iCalendar recurrence rule.
+type rrule struct {
+	freq     string
+	interval int
+	count    int
+	until    time.Time
+	weekdays []time.Weekday
+}
+
+var icsWeekdays = map[string]time.Weekday{
+	"MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday, "TH": time.Thursday,
+	"FR": time.Friday, "SA": time.Saturday, "SU": time.Sunday,
+}
+
+func parseRRule(value string) (rrule, error) {
+	rule := rrule{interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		key, val, _ := strings.Cut(part, "=")
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			rule.freq = strings.ToUpper(val)
+		case "INTERVAL":
+			if rule.interval, err = strconv.Atoi(val); err == nil && rule.interval < 1 {
+				err = errors.New("interval has to be positive")
+			}
+		case "COUNT":
+			rule.count, err = strconv.Atoi(val)
+		case "UNTIL":
+			var allDay bool
+			rule.until, allDay, err = parseICSTime(val, nil)
+			if allDay {
+				// the last day is included
+				rule.until = rule.until.AddDate(0, 0, 1).Add(-time.Nanosecond)
+			}
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := icsWeekdays[strings.ToUpper(day)]
+				if !ok {
+					return rule, fmt.Errorf("unsupported recurrence %q", value)
+				}
+				rule.weekdays = append(rule.weekdays, weekday)
+			}
+		case "WKST":
+			// weeks start on Monday
+		default:
+			return rule, fmt.Errorf("unsupported recurrence %q", value)
+		}
+		if err != nil {
+			return rule, fmt.Errorf("invalid recurrence %q: %w", value, err)
+		}
+	}
+	switch rule.freq {
+	case "DAILY", "WEEKLY":
+	case "MONTHLY", "YEARLY":
+		if rule.weekdays != nil {
+			return rule, fmt.Errorf("unsupported recurrence %q", value)
+		}
+	default:
+		return rule, fmt.Errorf("unsupported recurrence %q", value)
+	}
+	return rule, nil
+}
+
+// starts lists the starts of the occurrences of a series beginning at first,
+// up to the limit. Dates that don't exist, like February 30, are skipped.
+func (rule rrule) starts(first, limit time.Time) []time.Time {
+	var starts []time.Time
+	matchesDay := func(t time.Time) bool {
+		return rule.weekdays == nil || slices.Contains(rule.weekdays, t.Weekday())
+	}
+	for n := 0; ; n++ {
+		var period []time.Time
+		switch rule.freq {
+		case "DAILY":
+			period = []time.Time{first.AddDate(0, 0, n*rule.interval)}
+		case "WEEKLY":
+			week := first.AddDate(0, 0, n*7*rule.interval)
+			if rule.weekdays == nil {
+				period = []time.Time{week}
+				break
+			}
+			monday := week.AddDate(0, 0, -(int(week.Weekday())+6)%7)
+			for day := 0; day < 7; day++ {
+				period = append(period, monday.AddDate(0, 0, day))
+			}
+		case "MONTHLY":
+			period = []time.Time{first.AddDate(0, n*rule.interval, 0)}
+		case "YEARLY":
+			period = []time.Time{first.AddDate(n*rule.interval, 0, 0)}
+		}
+		for _, start := range period {
+			if !start.Before(limit) {
+				return starts
+			}
+			if start.Before(first) || !matchesDay(start) {
+				continue
+			}
+			if (rule.freq == "MONTHLY" || rule.freq == "YEARLY") && start.Day() != first.Day() {
+				continue
+			}
+			starts = append(starts, start)
+		}
+	}
+}
+
+// parseICSTime parses a DTSTART or DTEND value, which is a date, a UTC time,
+// a time in the zone given by the TZID parameter or a floating local time.
+// Times keep their zone, so recurrences follow its daylight saving time.
+func parseICSTime(value string, params []string) (time.Time, bool, error) {
+	location := time.Local
+	for _, param := range params {
+		key, val, _ := strings.Cut(param, "=")
+		switch strings.ToUpper(key) {
+		case "VALUE":
+			if strings.EqualFold(val, "DATE") {
+				t, err := time.ParseInLocation("20060102", value, time.Local)
+				return t, true, err
+			}
+		case "TZID":
+			// unknown zones, e.g. Windows names, fall back to local time
+			if loc, err := time.LoadLocation(strings.Trim(val, `"`)); err == nil {
+				location = loc
+			}
+		}
+	}
+	if len(value) == len("20060102") {
+		t, err := time.ParseInLocation("20060102", value, time.Local)
+		return t, true, err
+	}
+	if strings.HasSuffix(value, "Z") {
+		t, err := time.Parse(icsLayout, value)
+		return t, false, err
+	}
+	t, err := time.ParseInLocation("20060102T150405", value, location)
+	return t, false, err
+}
+
+// parseICSDuration parses durations like PT1H30M or P1D.
+func parseICSDuration(value string) (time.Duration, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(value, "+"), "P")
+	var duration time.Duration
+	inTime := false
+	number := 0
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			number = number*10 + int(c-'0')
+			continue
+		case c == 'T':
+			inTime = true
+		case c == 'W':
+			duration += time.Duration(number) * 7 * 24 * time.Hour
+		case c == 'D':
+			duration += time.Duration(number) * 24 * time.Hour
+		case c == 'H' && inTime:
+			duration += time.Duration(number) * time.Hour
+		case c == 'M' && inTime:
+			duration += time.Duration(number) * time.Minute
+		case c == 'S' && inTime:
+			duration += time.Duration(number) * time.Second
+		default:
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		number = 0
+	}
+	return duration, nil
+}
+
+var icsUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func icsUnescape(s string) string {
+	return icsUnescaper.Replace(s)
+}
+
+// ICSRule maps events to a project tag by a regular expression on the
+// summary or by the organizer's address. Empty criteria match every event.
+type ICSRule struct {
+	Summary   *regexp.Regexp
+	Organizer string
+	Tag       string
+}
+
+// Match reports whether the rule applies to the event.
+func (rule ICSRule) Match(event ICSEvent) bool {
+	if rule.Summary != nil && !rule.Summary.MatchString(event.Summary) {
+		return false
+	}
+	if rule.Organizer != "" && !strings.EqualFold(rule.Organizer, event.Organizer) {
+		return false
+	}
+	return true
+}
+
+// MatchICSRules returns the tag of the first rule matching the event.
+func MatchICSRules(event ICSEvent, rules []ICSRule) (string, bool) {
+	for _, rule := range rules {
+		if rule.Match(event) {
+			return rule.Tag, true
+		}
+	}
+	return "", false
+}
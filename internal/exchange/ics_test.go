@@ -0,0 +1,163 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const icsFixture = `BEGIN:VCALENDAR
+BEGIN:VEVENT
+UID:sync
+SUMMARY:Sync
+DESCRIPTION:Agenda
+DTSTART:20240311T080000Z
+DTEND:20240311T090000Z
+BEGIN:VALARM
+ACTION:DISPLAY
+DESCRIPTION:This is an event reminder
+TRIGGER:-PT10M
+END:VALARM
+END:VEVENT
+BEGIN:VEVENT
+UID:cancelled
+SUMMARY:Cancelled
+STATUS:CANCELLED
+DTSTART:20240312T080000Z
+DTEND:20240312T090000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+SUMMARY:Standup
+DTSTART:20240304T070000Z
+DURATION:PT15M
+RRULE:FREQ=WEEKLY;BYDAY=MO,WE,FR;UNTIL=20240331T000000Z
+EXDATE:20240313T070000Z
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+RECURRENCE-ID:20240315T070000Z
+SUMMARY:Standup moved
+DTSTART:20240315T100000Z
+DURATION:PT15M
+END:VEVENT
+BEGIN:VEVENT
+UID:standup
+RECURRENCE-ID:20240311T070000Z
+STATUS:CANCELLED
+DTSTART:20240311T070000Z
+DURATION:PT15M
+END:VEVENT
+END:VCALENDAR
+`
+
+func TestReadICS(t *testing.T) {
+	events, err := ReadICS(strings.NewReader(icsFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("got %d events, want 3", len(events))
+	}
+	if events[0].Description != "Agenda" {
+		t.Errorf("description = %q, the alarm's must not replace the event's", events[0].Description)
+	}
+	for _, event := range events {
+		if event.UID == "cancelled" {
+			t.Error("cancelled event was read")
+		}
+	}
+	standup := events[1]
+	if standup.Recurrence == "" || len(standup.Exceptions) != 3 {
+		t.Errorf("standup recurrence %q with %d exceptions, want 3", standup.Recurrence, len(standup.Exceptions))
+	}
+}
+
+func TestOccurrences(t *testing.T) {
+	events, err := ReadICS(strings.NewReader(icsFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	standup := events[1]
+	from := time.Date(2024, 3, 11, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	occurrences, err := standup.Occurrences(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Monday is cancelled, Wednesday excluded, Friday moved
+	if len(occurrences) != 0 {
+		t.Errorf("got %d occurrences in the week, want 0", len(occurrences))
+	}
+
+	occurrences, err = standup.Occurrences(to, to.AddDate(0, 1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Mar 18, 20, 22, 25, 27, 29
+	if len(occurrences) != 6 {
+		t.Fatalf("got %d occurrences until the end of March, want 6", len(occurrences))
+	}
+	first := occurrences[0]
+	if !first.Start.Equal(time.Date(2024, 3, 18, 7, 0, 0, 0, time.UTC)) || first.End.Sub(first.Start) != 15*time.Minute {
+		t.Errorf("first occurrence %v - %v", first.Start, first.End)
+	}
+}
+
+func TestOccurrencesRules(t *testing.T) {
+	start := time.Date(2024, 1, 31, 9, 0, 0, 0, time.UTC)
+	event := ICSEvent{Start: start, End: start.Add(time.Hour)}
+	year := start.AddDate(1, 0, 0)
+	tests := []struct {
+		rule string
+		want int
+		err  bool
+	}{
+		{rule: "FREQ=DAILY;COUNT=5", want: 5},
+		{rule: "FREQ=DAILY;INTERVAL=2;UNTIL=20240209", want: 5},
+		{rule: "FREQ=WEEKLY;COUNT=3", want: 3},
+		// months without a 31st are skipped
+		{rule: "FREQ=MONTHLY", want: 7},
+		{rule: "FREQ=YEARLY", want: 1},
+		{rule: "FREQ=MONTHLY;BYSETPOS=1;BYDAY=MO", err: true},
+		{rule: "FREQ=HOURLY", err: true},
+		{rule: "FREQ=DAILY;INTERVAL=0", err: true},
+	}
+	for _, test := range tests {
+		event.Recurrence = test.rule
+		occurrences, err := event.Occurrences(start, year)
+		if (err != nil) != test.err {
+			t.Errorf("%s: error %v", test.rule, err)
+			continue
+		}
+		if len(occurrences) != test.want {
+			t.Errorf("%s: got %d occurrences, want %d", test.rule, len(occurrences), test.want)
+		}
+	}
+}
+
+func TestOccurrencesKeepTimeZone(t *testing.T) {
+	fixture := "BEGIN:VEVENT\nUID:daily\nDTSTART;TZID=Europe/Vienna:20240329T090000\nDURATION:PT1H\nRRULE:FREQ=DAILY;COUNT=4\nEND:VEVENT\n"
+	events, err := ReadICS(strings.NewReader(fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vienna, err := time.LoadLocation("Europe/Vienna")
+	if err != nil {
+		t.Skip(err)
+	}
+	start := time.Date(2024, 3, 29, 0, 0, 0, 0, vienna)
+	occurrences, err := events[0].Occurrences(start, start.AddDate(0, 0, 7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(occurrences) != 4 {
+		t.Fatalf("got %d occurrences, want 4", len(occurrences))
+	}
+	// daylight saving time starts on March 31
+	for _, occurrence := range occurrences {
+		if hour := occurrence.Start.In(vienna).Hour(); hour != 9 {
+			t.Errorf("occurrence on %v starts at %d in Vienna, want 9", occurrence.Start, hour)
+		}
+	}
+}
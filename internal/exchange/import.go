@@ -0,0 +1,152 @@
+package exchange
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+// Import row states.
+const (
+	StatusNew       = "new"
+	StatusDuplicate = "duplicate"
+	StatusInvalid   = "invalid"
+)
+
+// ImportRow is a recording read from an import file.
+type ImportRow struct {
+	// Line is the position in the file, used in messages.
+	Line int
+	Row
+	Status string
+	Err    error
+}
+
+// ImportPlan is the outcome of checking import rows against the repository.
+type ImportPlan struct {
+	Rows []ImportRow
+	// NewProjects are created for rows of unknown projects.
+	NewProjects []domain.Project
+}
+
+// Count returns the number of rows in the given state.
+func (p *ImportPlan) Count(status string) int {
+	count := 0
+	for _, row := range p.Rows {
+		if row.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// Recordings returns the recordings to insert.
+func (p *ImportPlan) Recordings() []domain.Recording {
+	var recordings []domain.Recording
+	for _, row := range p.Rows {
+		if row.Status == StatusNew {
+			recordings = append(recordings, row.Recording)
+		}
+	}
+	return recordings
+}
+
+// Plan validates the rows and marks recordings that already exist, in the
+// repository or earlier in the file, as duplicates. Rows of unknown projects
+// are invalid unless createProjects is set; their projects are only created
+// if at least one of the rows is imported.
+func Plan(repo domain.Repository, rows []ImportRow, createProjects bool) (*ImportPlan, error) {
+	plan := &ImportPlan{}
+
+	var from, to time.Time
+	for _, row := range rows {
+		if row.Err != nil {
+			continue
+		}
+		if from.IsZero() || row.StartTime.Before(from) {
+			from = row.StartTime
+		}
+		if row.EndTime.After(to) {
+			to = row.EndTime
+		}
+	}
+
+	seen := map[string]bool{}
+	if !from.IsZero() {
+		existing, err := repo.FindRecordings(domain.RecordingFilter{From: from, To: to})
+		if err != nil {
+			return nil, err
+		}
+		for _, recording := range existing {
+			seen[duplicateKey(recording)] = true
+		}
+	}
+
+	projects := map[string]bool{}
+	// projects to create, kept only if one of their rows is imported
+	var candidates []domain.Project
+	imported := map[string]bool{}
+	for _, row := range rows {
+		switch {
+		case row.Err != nil:
+			row.Status = StatusInvalid
+		case !projects[row.ProjectTag] && !projectExists(repo, row.ProjectTag):
+			if !createProjects {
+				row.Status = StatusInvalid
+				row.Err = fmt.Errorf("project %q does not exist", row.ProjectTag)
+				break
+			}
+			if len(row.ProjectTag) > 10 {
+				row.Status = StatusInvalid
+				row.Err = fmt.Errorf("tag %q is too long", row.ProjectTag)
+				break
+			}
+			project := row.Project
+			project.Tag = row.ProjectTag
+			if project.Name == "" {
+				project.Name = project.Tag
+			}
+			if project.Type == "" {
+				project.Type = "other"
+			}
+			candidates = append(candidates, project)
+			projects[row.ProjectTag] = true
+			row.Status = StatusNew
+		default:
+			projects[row.ProjectTag] = true
+			row.Status = StatusNew
+		}
+
+		if row.Status == StatusNew {
+			key := duplicateKey(row.Recording)
+			if seen[key] {
+				row.Status = StatusDuplicate
+			} else {
+				imported[row.ProjectTag] = true
+			}
+			seen[key] = true
+		}
+		plan.Rows = append(plan.Rows, row)
+	}
+	for _, project := range candidates {
+		if imported[project.Tag] {
+			plan.NewProjects = append(plan.NewProjects, project)
+		}
+	}
+	return plan, nil
+}
+
+func projectExists(repo domain.Repository, tag string) bool {
+	_, err := repo.GetProjectByTag(tag)
+	return err == nil
+}
+
+// duplicateKey identifies a recording by project, start and end, to the
+// minute, as most formats don't store seconds.
+func duplicateKey(recording domain.Recording) string {
+	return recording.ProjectTag + "|" +
+		strconv.FormatInt(recording.StartTime.Truncate(time.Minute).Unix(), 10) + "|" +
+		strconv.FormatInt(recording.EndTime.Truncate(time.Minute).Unix(), 10)
+}
@@ -0,0 +1,55 @@
+package exchange
+
+import (
+	"testing"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+func TestPlan(t *testing.T) {
+	repo := domain.NewMemoryRepository()
+	if _, err := repo.CreateProject(domain.Project{Tag: "DAG", Name: "DAG", Type: "customer"}); err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+	if _, err := repo.CreateRecording(domain.Recording{ProjectTag: "DAG", StartTime: day.Add(9 * time.Hour), EndTime: day.Add(10 * time.Hour), Name: "stored"}); err != nil {
+		t.Fatal(err)
+	}
+	row := func(tag string, start, end float64) ImportRow {
+		return ImportRow{Row: Row{Recording: domain.Recording{
+			ProjectTag: tag,
+			StartTime:  day.Add(time.Duration(start * float64(time.Hour))),
+			EndTime:    day.Add(time.Duration(end * float64(time.Hour))),
+		}}}
+	}
+	rows := []ImportRow{
+		row("DAG", 9, 10),
+		row("DAG", 11, 12),
+		row("WEB", 9, 10),
+		row("WEB", 9, 10),
+		row("WEB", 13, 14),
+	}
+
+	plan, err := Plan(repo, rows, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{StatusDuplicate, StatusNew, StatusNew, StatusDuplicate, StatusNew}
+	for i, row := range plan.Rows {
+		if row.Status != want[i] {
+			t.Errorf("row %d is %s, want %s", i, row.Status, want[i])
+		}
+	}
+	if len(plan.NewProjects) != 1 || plan.NewProjects[0].Tag != "WEB" || plan.NewProjects[0].Type != "other" {
+		t.Errorf("new projects %+v, want WEB", plan.NewProjects)
+	}
+
+	plan, err = Plan(repo, rows, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Count(StatusInvalid) != 3 || len(plan.NewProjects) != 0 {
+		t.Errorf("without creating projects %d rows are invalid and %d projects new", plan.Count(StatusInvalid), len(plan.NewProjects))
+	}
+}
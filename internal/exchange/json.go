@@ -0,0 +1,537 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+const (
+	BackupFormat = "timetracking-backup"
+	// BackupVersion is increased whenever the backup format changes. Files
+	// of newer versions are rejected, older ones lack the fields added since.
+	BackupVersion = 10
+)
+
+// Backup is the lossless JSON representation of all stored data.
+type Backup struct {
+	Format     string            `json:"format"`
+	Version    int               `json:"version"`
+	CreatedAt  time.Time         `json:"createdAt"`
+	Projects   []BackupProject   `json:"projects"`
+	Recordings []BackupRecording `json:"recordings"`
+	// since version 3
+	Invoices []BackupInvoice `json:"invoices"`
+	// since version 4
+	Clients []BackupClient `json:"clients"`
+	// since version 5
+	Tasks []BackupTask `json:"tasks"`
+	// since version 8
+	ProjectTypes []BackupProjectType `json:"projectTypes"`
+}
+
+type BackupProjectType struct {
+	Name            string `json:"name"`
+	BillableDefault bool   `json:"billableDefault"`
+	Color           string `json:"color,omitempty"`
+}
+
+type BackupClient struct {
+	ID         int64   `json:"id"`
+	Name       string  `json:"name"`
+	Address    string  `json:"address,omitempty"`
+	VATID      string  `json:"vatId,omitempty"`
+	HourlyRate float64 `json:"hourlyRate,omitempty"`
+	Currency   string  `json:"currency,omitempty"`
+}
+
+type BackupProject struct {
+	Tag  string `json:"tag"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Status is a domain.ProjectStatus since version 9, before 0 was active
+	// and 1 inactive.
+	Status domain.ProjectStatus `json:"status"`
+	// since version 2
+	HourlyRate float64 `json:"hourlyRate,omitempty"`
+	// since version 4
+	ClientID int64 `json:"clientId,omitempty"`
+	// since version 6
+	BudgetHours  float64 `json:"budgetHours,omitempty"`
+	BudgetAmount float64 `json:"budgetAmount,omitempty"`
+	BudgetPeriod string  `json:"budgetPeriod,omitempty"`
+}
+
+type BackupTask struct {
+	ID              int64  `json:"id"`
+	ProjectTag      string `json:"projectTag"`
+	Name            string `json:"name"`
+	EstimateMinutes int64  `json:"estimateMinutes,omitempty"`
+	Done            bool   `json:"done"`
+}
+
+type BackupRecording struct {
+	ID         int64      `json:"id"`
+	ProjectTag string     `json:"projectTag"`
+	Start      time.Time  `json:"start"`
+	End        *time.Time `json:"end"`
+	Name       string     `json:"name"`
+	Billable   bool       `json:"billable"`
+	Note       string     `json:"note"`
+	Status     int        `json:"status"`
+	// since version 2
+	HourlyRate float64 `json:"hourlyRate,omitempty"`
+	// since version 3
+	InvoiceNumber int64 `json:"invoiceNumber,omitempty"`
+	// since version 5
+	TaskID int64 `json:"taskId,omitempty"`
+	// since version 7
+	Labels []string `json:"labels,omitempty"`
+}
+
+type BackupInvoice struct {
+	Number     int64     `json:"number"`
+	ProjectTag string    `json:"projectTag"`
+	IssuedAt   time.Time `json:"issuedAt"`
+	From       time.Time `json:"from"`
+	To         time.Time `json:"to"`
+	Currency   string    `json:"currency"`
+	Amount     float64   `json:"amount"`
+	// since version 10
+	Lines []BackupInvoiceLine `json:"lines,omitempty"`
+}
+
+type BackupInvoiceLine struct {
+	Date   time.Time `json:"date"`
+	Name   string    `json:"name"`
+	Hours  float64   `json:"hours"`
+	Rate   float64   `json:"rate"`
+	Amount float64   `json:"amount"`
+}
+
+// NewBackup builds a backup of the given data.
+func NewBackup(data domain.Dataset) *Backup {
+	backup := &Backup{
+		Format:     BackupFormat,
+		Version:    BackupVersion,
+		CreatedAt:  time.Now(),
+		Projects:   []BackupProject{},
+		Recordings: []BackupRecording{},
+		Invoices:   []BackupInvoice{},
+		Clients:    []BackupClient{},
+		Tasks:      []BackupTask{},
+
+		ProjectTypes: []BackupProjectType{},
+	}
+	for _, projectType := range data.ProjectTypes {
+		backup.ProjectTypes = append(backup.ProjectTypes, BackupProjectType(projectType))
+	}
+	for _, client := range data.Clients {
+		backup.Clients = append(backup.Clients, BackupClient(client))
+	}
+	for _, project := range data.Projects {
+		backup.Projects = append(backup.Projects, BackupProject(project))
+	}
+	for _, task := range data.Tasks {
+		backup.Tasks = append(backup.Tasks, BackupTask{
+			ID:              task.ID,
+			ProjectTag:      task.ProjectTag,
+			Name:            task.Name,
+			EstimateMinutes: int64(task.Estimate / time.Minute),
+			Done:            task.Done,
+		})
+	}
+	for _, invoice := range data.Invoices {
+		backupInvoice := BackupInvoice{
+			Number:     invoice.Number,
+			ProjectTag: invoice.ProjectTag,
+			IssuedAt:   invoice.IssuedAt,
+			From:       invoice.From,
+			To:         invoice.To,
+			Currency:   invoice.Currency,
+			Amount:     invoice.Amount,
+		}
+		for _, line := range invoice.Lines {
+			backupInvoice.Lines = append(backupInvoice.Lines, BackupInvoiceLine(line))
+		}
+		backup.Invoices = append(backup.Invoices, backupInvoice)
+	}
+	for _, recording := range data.Recordings {
+		r := BackupRecording{
+			ID:         recording.ID,
+			ProjectTag: recording.ProjectTag,
+			Start:      recording.StartTime,
+			Name:       recording.Name,
+			Billable:   recording.Billable,
+			Note:       recording.Note,
+			Status:     recording.Status,
+			HourlyRate: recording.HourlyRate,
+
+			InvoiceNumber: recording.InvoiceNumber,
+			TaskID:        recording.TaskID,
+			Labels:        recording.Labels,
+		}
+		if !recording.IsRunning() {
+			end := recording.EndTime
+			r.End = &end
+		}
+		backup.Recordings = append(backup.Recordings, r)
+	}
+	return backup
+}
+
+// DomainProjects returns the projects of the backup. Inactive projects of
+// backups before version 9 are archived.
+func (b *Backup) DomainProjects() []domain.Project {
+	var projects []domain.Project
+	for _, project := range b.Projects {
+		if b.Version < 9 && project.Status == 1 {
+			project.Status = domain.StatusArchived
+		}
+		projects = append(projects, domain.Project(project))
+	}
+	return projects
+}
+
+// DomainProjectTypes returns the project types of the backup. Backups
+// before version 8 have the default types and the types of their projects.
+func (b *Backup) DomainProjectTypes() []domain.ProjectType {
+	var projectTypes []domain.ProjectType
+	if b.Version >= 8 {
+		for _, projectType := range b.ProjectTypes {
+			projectTypes = append(projectTypes, domain.ProjectType(projectType))
+		}
+		return projectTypes
+	}
+	projectTypes = append(projectTypes, domain.DefaultProjectTypes...)
+	for _, project := range b.Projects {
+		if !slices.ContainsFunc(projectTypes, func(t domain.ProjectType) bool { return t.Name == project.Type }) {
+			projectTypes = append(projectTypes, domain.ProjectType{Name: project.Type})
+		}
+	}
+	return projectTypes
+}
+
+// DomainClients returns the clients of the backup.
+func (b *Backup) DomainClients() []domain.Client {
+	var clients []domain.Client
+	for _, client := range b.Clients {
+		clients = append(clients, domain.Client(client))
+	}
+	return clients
+}
+
+// DomainRecordings returns the recordings of the backup. Times are converted
+// to local time, which the stored recordings are in.
+func (b *Backup) DomainRecordings() []domain.Recording {
+	var recordings []domain.Recording
+	for _, recording := range b.Recordings {
+		r := domain.Recording{
+			ID:         recording.ID,
+			ProjectTag: recording.ProjectTag,
+			StartTime:  recording.Start.Local(),
+			Name:       recording.Name,
+			Billable:   recording.Billable,
+			Note:       recording.Note,
+			Status:     recording.Status,
+			HourlyRate: recording.HourlyRate,
+
+			InvoiceNumber: recording.InvoiceNumber,
+			TaskID:        recording.TaskID,
+			Labels:        recording.Labels,
+		}
+		if recording.End != nil {
+			r.EndTime = recording.End.Local()
+		}
+		recordings = append(recordings, r)
+	}
+	return recordings
+}
+
+// DomainTasks returns the tasks of the backup.
+func (b *Backup) DomainTasks() []domain.Task {
+	var tasks []domain.Task
+	for _, task := range b.Tasks {
+		tasks = append(tasks, domain.Task{
+			ID:         task.ID,
+			ProjectTag: task.ProjectTag,
+			Name:       task.Name,
+			Estimate:   time.Duration(task.EstimateMinutes) * time.Minute,
+			Done:       task.Done,
+		})
+	}
+	return tasks
+}
+
+// DomainInvoices returns the invoices of the backup, in local time like the
+// recordings.
+func (b *Backup) DomainInvoices() []domain.Invoice {
+	var invoices []domain.Invoice
+	for _, backupInvoice := range b.Invoices {
+		invoice := domain.Invoice{
+			Number:     backupInvoice.Number,
+			ProjectTag: backupInvoice.ProjectTag,
+			IssuedAt:   backupInvoice.IssuedAt.Local(),
+			From:       backupInvoice.From.Local(),
+			To:         backupInvoice.To.Local(),
+			Currency:   backupInvoice.Currency,
+			Amount:     backupInvoice.Amount,
+		}
+		for _, line := range backupInvoice.Lines {
+			line.Date = line.Date.Local()
+			invoice.Lines = append(invoice.Lines, domain.InvoiceLine(line))
+		}
+		invoices = append(invoices, invoice)
+	}
+	return invoices
+}
+
+func WriteBackup(w io.Writer, backup *Backup) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(backup)
+}
+
+// ReadBackup reads a backup and rejects files of other formats or versions.
+func ReadBackup(r io.Reader) (*Backup, error) {
+	var backup Backup
+	if err := json.NewDecoder(r).Decode(&backup); err != nil {
+		return nil, err
+	}
+	if backup.Format != BackupFormat {
+		return nil, fmt.Errorf("not a timetracking backup")
+	}
+	if backup.Version < 1 || backup.Version > BackupVersion {
+		return nil, fmt.Errorf("unsupported backup version %d, expected at most %d", backup.Version, BackupVersion)
+	}
+
+	projectTypes := map[string]bool{}
+	for _, projectType := range backup.ProjectTypes {
+		if projectTypes[projectType.Name] {
+			return nil, fmt.Errorf("project type %q is listed twice", projectType.Name)
+		}
+		if !slices.Contains(domain.ProjectTypeColors, projectType.Color) {
+			return nil, fmt.Errorf("project type %q has an unknown color %q", projectType.Name, projectType.Color)
+		}
+		projectTypes[projectType.Name] = true
+	}
+	clients := map[int64]bool{}
+	for _, client := range backup.Clients {
+		if clients[client.ID] {
+			return nil, fmt.Errorf("client id %d is used twice", client.ID)
+		}
+		clients[client.ID] = true
+	}
+	tags := map[string]bool{}
+	for _, project := range backup.Projects {
+		if !project.Status.Valid() {
+			return nil, fmt.Errorf("project %q has an unknown status %d", project.Tag, project.Status)
+		}
+		if !slices.Contains(domain.BudgetPeriods, project.BudgetPeriod) {
+			return nil, fmt.Errorf("project %q has an unknown budget period %q", project.Tag, project.BudgetPeriod)
+		}
+		if backup.Version >= 8 && !projectTypes[project.Type] {
+			return nil, fmt.Errorf("project %q has an unknown type %q", project.Tag, project.Type)
+		}
+		if project.ClientID != 0 && !clients[project.ClientID] {
+			return nil, fmt.Errorf("project %q references unknown client %d", project.Tag, project.ClientID)
+		}
+		tags[project.Tag] = true
+	}
+	tasks := map[int64]string{}
+	for _, task := range backup.Tasks {
+		if !tags[task.ProjectTag] {
+			return nil, fmt.Errorf("task %d references unknown project %q", task.ID, task.ProjectTag)
+		}
+		if _, ok := tasks[task.ID]; ok {
+			return nil, fmt.Errorf("task id %d is used twice", task.ID)
+		}
+		tasks[task.ID] = task.ProjectTag
+	}
+	invoices := map[int64]bool{}
+	for _, invoice := range backup.Invoices {
+		if !tags[invoice.ProjectTag] {
+			return nil, fmt.Errorf("invoice %d references unknown project %q", invoice.Number, invoice.ProjectTag)
+		}
+		invoices[invoice.Number] = true
+	}
+	ids := map[int64]bool{}
+	var running *BackupRecording
+	for i, recording := range backup.Recordings {
+		if !tags[recording.ProjectTag] {
+			return nil, fmt.Errorf("recording %d references unknown project %q", recording.ID, recording.ProjectTag)
+		}
+		if recording.InvoiceNumber != 0 && !invoices[recording.InvoiceNumber] {
+			return nil, fmt.Errorf("recording %d references unknown invoice %d", recording.ID, recording.InvoiceNumber)
+		}
+		if tag, ok := tasks[recording.TaskID]; recording.TaskID != 0 && (!ok || tag != recording.ProjectTag) {
+			return nil, fmt.Errorf("recording %d references unknown task %d", recording.ID, recording.TaskID)
+		}
+		for i, label := range recording.Labels {
+			if label == "" || slices.Contains(recording.Labels[:i], label) {
+				return nil, fmt.Errorf("recording %d has an empty or repeated label", recording.ID)
+			}
+		}
+		if ids[recording.ID] {
+			return nil, fmt.Errorf("recording id %d is used twice", recording.ID)
+		}
+		ids[recording.ID] = true
+		if recording.End == nil {
+			if running != nil {
+				return nil, fmt.Errorf("recordings %d and %d are both running", running.ID, recording.ID)
+			}
+			running = &backup.Recordings[i]
+		}
+	}
+	return &backup, nil
+}
+
+// RestorePlan is what restoring a backup would store.
+type RestorePlan struct {
+	domain.Dataset
+	// Skipped counts data that already exists.
+	SkippedProjectTypes int
+	SkippedClients      int
+	SkippedProjects     int
+	SkippedTasks        int
+	SkippedRecordings   int
+	SkippedInvoices     int
+	// Renumbered counts recordings whose ID is taken by another recording.
+	Renumbered int
+}
+
+// PlanRestore decides what to store for a backup. Replacing stores everything
+// as is. Merging keeps existing project types, clients, projects, tasks and
+// invoices, skips recordings that already exist and gives new IDs to
+// recordings whose ID is taken. Clients, tasks and invoices whose ID is taken
+// by different data can't be merged.
+func PlanRestore(repo domain.Repository, backup *Backup, replace bool) (*RestorePlan, error) {
+	plan := &RestorePlan{}
+	if replace {
+		plan.ProjectTypes = backup.DomainProjectTypes()
+		plan.Clients = backup.DomainClients()
+		plan.Projects = backup.DomainProjects()
+		plan.Tasks = backup.DomainTasks()
+		plan.Recordings = backup.DomainRecordings()
+		plan.Invoices = backup.DomainInvoices()
+		return plan, nil
+	}
+
+	for _, projectType := range backup.DomainProjectTypes() {
+		if _, err := repo.GetProjectType(projectType.Name); err == nil {
+			plan.SkippedProjectTypes++
+			continue
+		} else if !errors.Is(err, domain.ErrNotExists) {
+			return nil, err
+		}
+		plan.ProjectTypes = append(plan.ProjectTypes, projectType)
+	}
+
+	clients, err := repo.AllClients()
+	if err != nil {
+		return nil, err
+	}
+	storedClients := map[int64]string{}
+	clientNames := map[string]int64{}
+	for _, client := range clients {
+		storedClients[client.ID] = client.Name
+		clientNames[client.Name] = client.ID
+	}
+	for _, client := range backup.DomainClients() {
+		name, idTaken := storedClients[client.ID]
+		id, nameTaken := clientNames[client.Name]
+		if idTaken && name == client.Name {
+			plan.SkippedClients++
+			continue
+		}
+		if idTaken {
+			return nil, fmt.Errorf("client %d already exists with different data, only a replace can restore it", client.ID)
+		} else if nameTaken {
+			return nil, fmt.Errorf("client %q already exists as client %d, only a replace can restore it", client.Name, id)
+		}
+		plan.Clients = append(plan.Clients, client)
+	}
+
+	for _, project := range backup.DomainProjects() {
+		if projectExists(repo, project.Tag) {
+			plan.SkippedProjects++
+			continue
+		}
+		plan.Projects = append(plan.Projects, project)
+	}
+
+	tasks, err := repo.AllTasks()
+	if err != nil {
+		return nil, err
+	}
+	storedTasks := map[int64]domain.Task{}
+	taskNames := map[string]int64{}
+	for _, task := range tasks {
+		storedTasks[task.ID] = task
+		taskNames[task.ProjectTag+"\x00"+task.Name] = task.ID
+	}
+	for _, task := range backup.DomainTasks() {
+		stored, idTaken := storedTasks[task.ID]
+		id, nameTaken := taskNames[task.ProjectTag+"\x00"+task.Name]
+		if idTaken && stored.ProjectTag == task.ProjectTag && stored.Name == task.Name {
+			plan.SkippedTasks++
+			continue
+		}
+		if idTaken {
+			return nil, fmt.Errorf("task %d already exists with different data, only a replace can restore it", task.ID)
+		} else if nameTaken {
+			return nil, fmt.Errorf("task %q of %s already exists as task %d, only a replace can restore it", task.Name, task.ProjectTag, id)
+		}
+		plan.Tasks = append(plan.Tasks, task)
+	}
+
+	for _, invoice := range backup.DomainInvoices() {
+		stored, err := repo.GetInvoice(invoice.Number)
+		if errors.Is(err, domain.ErrNotExists) {
+			plan.Invoices = append(plan.Invoices, invoice)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		if stored.ProjectTag != invoice.ProjectTag || math.Abs(stored.Amount-invoice.Amount) >= 0.01 {
+			return nil, fmt.Errorf("invoice %d already exists with different data, only a replace can restore it", invoice.Number)
+		}
+		plan.SkippedInvoices++
+	}
+
+	existing, err := repo.AllRecordings()
+	if err != nil {
+		return nil, err
+	}
+	ids := map[int64]bool{}
+	seen := map[string]bool{}
+	var running *domain.Recording
+	for i, recording := range existing {
+		ids[recording.ID] = true
+		seen[duplicateKey(recording)] = true
+		if recording.IsRunning() {
+			running = &existing[i]
+		}
+	}
+	for _, recording := range backup.DomainRecordings() {
+		if seen[duplicateKey(recording)] {
+			plan.SkippedRecordings++
+			continue
+		}
+		if recording.IsRunning() && running != nil {
+			return nil, fmt.Errorf("recording %d of the backup is running while %s - %s is, stop it first", recording.ID, running.ProjectTag, running.Name)
+		}
+		if ids[recording.ID] {
+			recording.ID = 0
+			plan.Renumbered++
+		}
+		plan.Recordings = append(plan.Recordings, recording)
+	}
+	return plan, nil
+}
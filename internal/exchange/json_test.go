@@ -0,0 +1,203 @@
+package exchange
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+var backupDay = time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+
+func backupAt(hours float64) time.Time {
+	return backupDay.Add(time.Duration(hours * float64(time.Hour)))
+}
+
+// backupSource stores a bit of everything a backup holds.
+func backupSource(t *testing.T) *domain.MemoryRepository {
+	t.Helper()
+	repo := domain.NewMemoryRepository()
+	client, err := repo.CreateClient(domain.Client{Name: "DAG GmbH", HourlyRate: 90, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateProject(domain.Project{Tag: "DAG", Name: "Customer DAG", Type: "customer", ClientID: client.ID, HourlyRate: 90}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateProject(domain.Project{Tag: "INT", Name: "Internal", Type: "internal", Status: domain.StatusOnHold}); err != nil {
+		t.Fatal(err)
+	}
+	task, err := repo.CreateTask(domain.Task{ProjectTag: "DAG", Name: "API design", Estimate: 6 * time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	billed, err := repo.CreateRecording(domain.Recording{ProjectTag: "DAG", StartTime: backupAt(9), EndTime: backupAt(11), Name: "review", Billable: true, TaskID: task.ID, Labels: []string{"meeting"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRecording(domain.Recording{ProjectTag: "INT", StartTime: backupAt(12), EndTime: backupAt(13), Name: "planning", Note: "q2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRecording(domain.Recording{ProjectTag: "DAG", StartTime: backupAt(14), Name: "running"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := []domain.InvoiceLine{{Date: backupDay, Name: "review", Hours: 2, Rate: 90, Amount: 180}}
+	if _, err := repo.CreateInvoice(domain.Invoice{ProjectTag: "DAG", IssuedAt: backupAt(15), From: backupDay, To: backupDay.AddDate(0, 0, 1), Currency: "USD", Amount: 180, Lines: lines}, []int64{billed.ID}); err != nil {
+		t.Fatal(err)
+	}
+	return repo
+}
+
+func dataset(t *testing.T, repo domain.Repository) domain.Dataset {
+	t.Helper()
+	var data domain.Dataset
+	var err error
+	if data.ProjectTypes, err = repo.AllProjectTypes(); err != nil {
+		t.Fatal(err)
+	}
+	if data.Clients, err = repo.AllClients(); err != nil {
+		t.Fatal(err)
+	}
+	if data.Projects, err = repo.AllProjects(); err != nil {
+		t.Fatal(err)
+	}
+	if data.Tasks, err = repo.AllTasks(); err != nil {
+		t.Fatal(err)
+	}
+	if data.Recordings, err = repo.AllRecordings(); err != nil {
+		t.Fatal(err)
+	}
+	if data.Invoices, err = repo.AllInvoices(); err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// roundTrip writes a backup of the data and reads it back.
+func roundTrip(t *testing.T, data domain.Dataset) *Backup {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := WriteBackup(&buf, NewBackup(data)); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := ReadBackup(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return backup
+}
+
+func TestBackupReplace(t *testing.T) {
+	want := dataset(t, backupSource(t))
+	backup := roundTrip(t, want)
+
+	repo := domain.NewMemoryRepository()
+	if _, err := repo.CreateProject(domain.Project{Tag: "OLD", Name: "replaced", Type: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	plan, err := PlanRestore(repo, backup, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(plan.Dataset, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := dataset(t, repo); !reflect.DeepEqual(got, want) {
+		t.Errorf("restored data differs\ngot  %+v\nwant %+v", got, want)
+	}
+}
+
+func TestBackupMerge(t *testing.T) {
+	source := dataset(t, backupSource(t))
+	backup := roundTrip(t, source)
+
+	repo := domain.NewMemoryRepository()
+	if _, err := repo.CreateProject(domain.Project{Tag: "DAG", Name: "Customer DAG", Type: "customer"}); err != nil {
+		t.Fatal(err)
+	}
+	// the same as the first recording of the backup
+	if _, err := repo.CreateRecording(domain.Recording{ProjectTag: "DAG", StartTime: backupAt(9), EndTime: backupAt(11), Name: "review"}); err != nil {
+		t.Fatal(err)
+	}
+	// takes the ID of the second one
+	if _, err := repo.CreateRecording(domain.Recording{ProjectTag: "DAG", StartTime: backupAt(7), EndTime: backupAt(8), Name: "early"}); err != nil {
+		t.Fatal(err)
+	}
+
+	plan, err := PlanRestore(repo, backup, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.SkippedProjects != 1 || plan.SkippedRecordings != 1 || plan.Renumbered != 1 {
+		t.Errorf("skipped %d projects and %d recordings, renumbered %d, want 1 each", plan.SkippedProjects, plan.SkippedRecordings, plan.Renumbered)
+	}
+	if err := repo.Restore(plan.Dataset, false); err != nil {
+		t.Fatal(err)
+	}
+	got := dataset(t, repo)
+	if len(got.Projects) != 2 || len(got.Tasks) != 1 || len(got.Recordings) != 4 || len(got.Invoices) != 1 {
+		t.Errorf("got %d projects, %d tasks, %d recordings and %d invoices, want 2, 1, 4 and 1", len(got.Projects), len(got.Tasks), len(got.Recordings), len(got.Invoices))
+	}
+	if !reflect.DeepEqual(got.Invoices, source.Invoices) {
+		t.Errorf("merged invoices %+v, want %+v", got.Invoices, source.Invoices)
+	}
+
+	// merging again finds everything
+	plan, err = PlanRestore(repo, roundTrip(t, source), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Recordings) != 0 || plan.SkippedRecordings != len(source.Recordings) {
+		t.Errorf("second merge stores %d recordings and skips %d", len(plan.Recordings), plan.SkippedRecordings)
+	}
+}
+
+func TestBackupMergeRunning(t *testing.T) {
+	backup := roundTrip(t, dataset(t, backupSource(t)))
+
+	repo := domain.NewMemoryRepository()
+	if _, err := repo.CreateProject(domain.Project{Tag: "DAG", Name: "Customer DAG", Type: "customer"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateRecording(domain.Recording{ProjectTag: "DAG", StartTime: backupAt(16), Name: "other"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := PlanRestore(repo, backup, false); err == nil {
+		t.Error("merging a second running recording: no error")
+	}
+}
+
+func TestReadBackup(t *testing.T) {
+	const header = `{"format": "timetracking-backup", "version": 7, "projects": [{"tag": "DAG", "name": "DAG", "type": "customer"}], `
+	backup, err := ReadBackup(strings.NewReader(header + `"recordings": [
+		{"id": 1, "projectTag": "DAG", "start": "2024-03-11T09:00:00+05:00", "end": "2024-03-11T10:00:00-03:00", "name": "offset"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recording := backup.DomainRecordings()[0]
+	if !recording.StartTime.Equal(time.Date(2024, 3, 11, 4, 0, 0, 0, time.UTC)) || !recording.EndTime.Equal(time.Date(2024, 3, 11, 13, 0, 0, 0, time.UTC)) {
+		t.Errorf("recording %v - %v", recording.StartTime, recording.EndTime)
+	}
+	if recording.StartTime.Location() != time.Local || recording.EndTime.Location() != time.Local {
+		t.Errorf("recording in %v, want local time", recording.StartTime.Location())
+	}
+
+	for name, input := range map[string]string{
+		"malformed":       `{"format": "timetracking-backup", "version": 10, "projects": [`,
+		"other format":    `{"format": "something", "version": 1}`,
+		"newer version":   `{"format": "timetracking-backup", "version": 99}`,
+		"unknown project": header + `"recordings": [{"id": 1, "projectTag": "WEB", "start": "2024-03-11T09:00:00Z"}]}`,
+		"repeated id": header + `"recordings": [{"id": 1, "projectTag": "DAG", "start": "2024-03-11T09:00:00Z", "end": "2024-03-11T10:00:00Z"},
+			{"id": 1, "projectTag": "DAG", "start": "2024-03-11T11:00:00Z", "end": "2024-03-11T12:00:00Z"}]}`,
+		"two running": header + `"recordings": [{"id": 1, "projectTag": "DAG", "start": "2024-03-11T09:00:00Z"},
+			{"id": 2, "projectTag": "DAG", "start": "2024-03-11T11:00:00Z"}]}`,
+	} {
+		if _, err := ReadBackup(strings.NewReader(input)); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
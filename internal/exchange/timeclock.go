@@ -0,0 +1,37 @@
+package exchange
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+const timeclockLayout = "2006/01/02 15:04:05"
+
+// TimeclockAccount is the account of a recording in the timeclock format,
+// the project type and tag, e.g. customer:DAG.
+func TimeclockAccount(row Row) string {
+	projectType := row.Project.Type
+	if projectType == "" {
+		projectType = "other"
+	}
+	return projectType + ":" + row.ProjectTag
+}
+
+// WriteTimeclock writes the rows as clock-in and clock-out lines of the
+// timeclock format read by hledger and ledger. The format has no time zones,
+// times are written in local time.
+func WriteTimeclock(w io.Writer, rows []Row) error {
+	for _, row := range rows {
+		line := "i " + row.StartTime.Local().Format(timeclockLayout) + " " + TimeclockAccount(row)
+		// the description is separated by two spaces, as single spaces
+		// are part of account names
+		if name := strings.Join(strings.Fields(row.Name), " "); name != "" {
+			line += "  " + name
+		}
+		if _, err := fmt.Fprintf(w, "%s\no %s\n", line, row.EndTime.Local().Format(timeclockLayout)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
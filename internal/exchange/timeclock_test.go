@@ -0,0 +1,35 @@
+package exchange
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+func TestWriteTimeclock(t *testing.T) {
+	start := time.Date(2024, 3, 11, 23, 0, 0, 0, time.Local)
+	rows := []Row{
+		{
+			Recording: domain.Recording{ProjectTag: "DAG", StartTime: start, EndTime: start.Add(90 * time.Minute), Name: " weekly\n sync "},
+			Project:   domain.Project{Tag: "DAG", Type: "open source"},
+		},
+		// an offset is written in local time, timeclock has no zones
+		{
+			Recording: domain.Recording{ProjectTag: "X", StartTime: time.Date(2024, 3, 11, 9, 0, 0, 0, time.FixedZone("", 5*3600)), EndTime: time.Date(2024, 3, 11, 10, 0, 0, 0, time.FixedZone("", 5*3600))},
+		},
+	}
+	var buf bytes.Buffer
+	if err := WriteTimeclock(&buf, rows); err != nil {
+		t.Fatal(err)
+	}
+	offset := rows[1].StartTime
+	want := "i 2024/03/11 23:00:00 open source:DAG  weekly sync\n" +
+		"o 2024/03/12 00:30:00\n" +
+		"i " + offset.Local().Format(timeclockLayout) + " other:X\n" +
+		"o " + offset.Add(time.Hour).Local().Format(timeclockLayout) + "\n"
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,185 @@
+package exchange
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// timewarrior stores intervals in UTC to the second.
+const timewarriorLayout = "20060102T150405Z"
+
+// billableTag marks billable recordings in formats without a billable flag.
+const billableTag = "billable"
+
+// WriteTimewarrior writes the rows as intervals of a timewarrior .data file.
+// The project tag is the first tag, followed by the name and the billable
+// tag; the note becomes the annotation.
+func WriteTimewarrior(w io.Writer, rows []Row) error {
+	for _, row := range rows {
+		line := "inc " + row.StartTime.UTC().Format(timewarriorLayout) +
+			" - " + row.EndTime.UTC().Format(timewarriorLayout)
+		line += " #"
+		for _, tag := range recordingTags(row) {
+			line += " " + timewarriorQuote(tag, false)
+		}
+		if row.Note != "" {
+			line += " # " + timewarriorQuote(row.Note, true)
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadTimewarrior reads the intervals of a timewarrior .data file.
+func ReadTimewarrior(r io.Reader) ([]ImportRow, error) {
+	var rows []ImportRow
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		row, err := parseTimewarriorLine(text)
+		rows = append(rows, ImportRow{Line: line, Row: row, Err: err})
+	}
+	return rows, scanner.Err()
+}
+
+func parseTimewarriorLine(line string) (Row, error) {
+	row := Row{}
+	tokens, err := timewarriorTokens(line)
+	if err != nil {
+		return row, err
+	}
+	if len(tokens) < 2 || tokens[0].text != "inc" {
+		return row, errors.New("not an interval")
+	}
+	if row.StartTime, err = time.Parse(timewarriorLayout, tokens[1].text); err != nil {
+		return row, fmt.Errorf("invalid start %q", tokens[1].text)
+	}
+	row.StartTime = row.StartTime.Local()
+	tokens = tokens[2:]
+	if len(tokens) < 2 || tokens[0].text != "-" {
+		return row, errors.New("interval is still open")
+	}
+	if row.EndTime, err = time.Parse(timewarriorLayout, tokens[1].text); err != nil {
+		return row, fmt.Errorf("invalid end %q", tokens[1].text)
+	}
+	row.EndTime = row.EndTime.Local()
+	tokens = tokens[2:]
+
+	// "# tags # annotation"
+	var tags, annotation []string
+	section := 0
+	for _, token := range tokens {
+		if token.text == "#" && !token.quoted {
+			section++
+			continue
+		}
+		switch section {
+		case 1:
+			tags = append(tags, token.text)
+		case 2:
+			annotation = append(annotation, token.text)
+		default:
+			return row, fmt.Errorf("unexpected %q", token.text)
+		}
+	}
+	row.Note = strings.Join(annotation, " ")
+	if len(tags) == 0 {
+		return row, errors.New("interval has no tags")
+	}
+	setRecordingTags(&row, tags[0], tags[1:])
+	if !row.EndTime.After(row.StartTime) {
+		return row, errors.New("end is not after start")
+	}
+	return row, nil
+}
+
+type timewarriorToken struct {
+	text   string
+	quoted bool
+}
+
+// timewarriorTokens splits a line at spaces, keeping quoted strings together.
+func timewarriorTokens(line string) ([]timewarriorToken, error) {
+	var tokens []timewarriorToken
+	for i := 0; i < len(line); {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		if line[i] != '"' {
+			end := strings.IndexByte(line[i:], ' ')
+			if end < 0 {
+				end = len(line) - i
+			}
+			tokens = append(tokens, timewarriorToken{text: line[i : i+end]})
+			i += end
+			continue
+		}
+
+		var text strings.Builder
+		closed := false
+		for i++; i < len(line); i++ {
+			if line[i] == '\\' && i+1 < len(line) {
+				i++
+				text.WriteByte(line[i])
+				continue
+			}
+			if line[i] == '"' {
+				closed = true
+				i++
+				break
+			}
+			text.WriteByte(line[i])
+		}
+		if !closed {
+			return nil, errors.New("unterminated quote")
+		}
+		tokens = append(tokens, timewarriorToken{text: text.String(), quoted: true})
+	}
+	return tokens, nil
+}
+
+func timewarriorQuote(s string, always bool) string {
+	if !always && s != "" && !strings.ContainsAny(s, " \"#\\") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// recordingTags returns the tags representing a recording in formats that
+// only know tags: the project tag, the name and the billable tag.
+func recordingTags(row Row) []string {
+	tags := []string{row.ProjectTag}
+	if row.Name != "" {
+		tags = append(tags, row.Name)
+	}
+	if row.Billable {
+		tags = append(tags, billableTag)
+	}
+	return tags
+}
+
+// setRecordingTags is the reverse of recordingTags. Additional tags are
+// joined into the name.
+func setRecordingTags(row *Row, project string, tags []string) {
+	row.ProjectTag = project
+	var names []string
+	for _, tag := range tags {
+		if tag == billableTag {
+			row.Billable = true
+			continue
+		}
+		names = append(names, tag)
+	}
+	row.Name = strings.Join(names, ", ")
+}
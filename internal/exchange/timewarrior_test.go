@@ -0,0 +1,68 @@
+package exchange
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+func TestReadTimewarrior(t *testing.T) {
+	input := `inc 20240311T080000Z - 20240311T093000Z # DAG review billable # "went \"well\""
+
+inc 20240311T220000Z - 20240312T003000Z # WEB "late fix" deploy
+inc 20240311T080000Z
+inc 20240311T080000Z - 20240311T090000Z
+inc 2024-03-11 - 20240311T090000Z # DAG
+inc 20240311T080000Z - 20240311T090000Z # DAG "open
+`
+	rows, err := ReadTimewarrior(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 6 {
+		t.Fatalf("got %d rows, want 6", len(rows))
+	}
+	review := rows[0]
+	if review.Err != nil || review.ProjectTag != "DAG" || review.Name != "review" || !review.Billable || review.Note != `went "well"` {
+		t.Errorf("review %+v, error %v", review.Row, review.Err)
+	}
+	if !review.StartTime.Equal(time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)) || review.Duration() != 90*time.Minute {
+		t.Errorf("review %v - %v", review.StartTime, review.EndTime)
+	}
+	if review.StartTime.Location() != time.Local {
+		t.Errorf("review in %v, want local time", review.StartTime.Location())
+	}
+	if fix := rows[1]; fix.Line != 3 || fix.Err != nil || fix.Name != "late fix, deploy" || fix.Duration() != 150*time.Minute {
+		t.Errorf("line %d: %+v, error %v", fix.Line, fix.Row, fix.Err)
+	}
+	// open, without tags, invalid start, unterminated quote
+	for _, row := range rows[2:] {
+		if row.Err == nil {
+			t.Errorf("line %d: no error", row.Line)
+		}
+	}
+}
+
+func TestTimewarriorRoundTrip(t *testing.T) {
+	start := time.Date(2024, 3, 11, 9, 0, 0, 0, time.Local)
+	want := Row{Recording: domain.Recording{ProjectTag: "DAG", StartTime: start, EndTime: start.Add(time.Hour), Name: `say "hi" #1`, Billable: true, Note: "a note"}}
+	var buf bytes.Buffer
+	if err := WriteTimewarrior(&buf, []Row{want}); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := ReadTimewarrior(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Err != nil {
+		t.Fatalf("read back %+v", rows)
+	}
+	got := rows[0]
+	if got.ProjectTag != want.ProjectTag || got.Name != want.Name || got.Billable != want.Billable || got.Note != want.Note ||
+		!got.StartTime.Equal(want.StartTime) || !got.EndTime.Equal(want.EndTime) {
+		t.Errorf("read back %+v, want %+v", got.Row, want)
+	}
+}
@@ -0,0 +1,193 @@
+package exchange
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+// TogglEntry is a time entry of a Toggl Track export.
+type TogglEntry struct {
+	Line        int
+	Client      string
+	Project     string
+	Description string
+	Start       time.Time
+	End         time.Time
+	Billable    bool
+	Tags        []string
+	Err         error
+}
+
+// Key identifies the Toggl project of the entry, which is mapped to a
+// project tag.
+func (e TogglEntry) Key() string {
+	project := e.Project
+	if project == "" {
+		project = "(no project)"
+	}
+	if e.Client != "" {
+		return e.Client + " / " + project
+	}
+	return project
+}
+
+// ReadTogglCSV reads the CSV export of a Toggl detailed report.
+func ReadTogglCSV(r io.Reader) ([]TogglEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading header: %w", err)
+	}
+	// exports may start with a byte order mark
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\uFEFF")
+	}
+	for _, column := range []string{"Project", "Description", "Start date", "Start time", "End date", "End time"} {
+		if columnIndex(header, column) < 0 {
+			return nil, fmt.Errorf("column %q not found, is this a Toggl detailed report?", column)
+		}
+	}
+
+	var entries []TogglEntry
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		value := func(column string) string {
+			i := columnIndex(header, column)
+			if i < 0 || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		entry := TogglEntry{
+			Line:        line,
+			Client:      value("Client"),
+			Project:     value("Project"),
+			Description: value("Description"),
+			Billable:    strings.EqualFold(value("Billable"), "yes"),
+		}
+		if tags := value("Tags"); tags != "" {
+			for _, tag := range strings.Split(tags, ",") {
+				entry.Tags = append(entry.Tags, strings.TrimSpace(tag))
+			}
+		}
+		entry.Start, entry.Err = time.ParseInLocation("2006-01-02 15:04:05", value("Start date")+" "+value("Start time"), time.Local)
+		if entry.Err == nil {
+			entry.End, entry.Err = time.ParseInLocation("2006-01-02 15:04:05", value("End date")+" "+value("End time"), time.Local)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// togglJSONEntry covers the time entries of the detailed report JSON (v2)
+// and the time entry export (v9), which name some fields differently.
+type togglJSONEntry struct {
+	Description string     `json:"description"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end"`
+	Stop        *time.Time `json:"stop"`
+	Project     string     `json:"project"`
+	ProjectName string     `json:"project_name"`
+	Client      string     `json:"client"`
+	ClientName  string     `json:"client_name"`
+	Billable    bool       `json:"billable"`
+	IsBillable  bool       `json:"is_billable"`
+	Tags        []string   `json:"tags"`
+}
+
+// ReadTogglJSON reads a Toggl JSON export, either a detailed report with a
+// "data" list or a plain list of time entries.
+func ReadTogglJSON(r io.Reader) ([]TogglEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var raw []togglJSONEntry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var report struct {
+			Data []togglJSONEntry `json:"data"`
+		}
+		if err := json.Unmarshal(data, &report); err != nil {
+			return nil, fmt.Errorf("not a Toggl JSON export: %w", err)
+		}
+		raw = report.Data
+	}
+
+	var entries []TogglEntry
+	for i, e := range raw {
+		entry := TogglEntry{
+			Line:        i + 1,
+			Client:      firstNonEmpty(e.Client, e.ClientName),
+			Project:     firstNonEmpty(e.Project, e.ProjectName),
+			Description: e.Description,
+			Start:       e.Start.Local(),
+			Billable:    e.Billable || e.IsBillable,
+			Tags:        e.Tags,
+		}
+		switch {
+		case e.End != nil:
+			entry.End = e.End.Local()
+		case e.Stop != nil:
+			entry.End = e.Stop.Local()
+		default:
+			entry.Err = errors.New("entry is still running")
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// TogglRows converts the entries to import rows using the mapping from entry
+// keys to project tags. Projects to be created are named after the Toggl
+// project; projects with a client become customer projects.
+func TogglRows(entries []TogglEntry, mapping map[string]string) []ImportRow {
+	var rows []ImportRow
+	for _, entry := range entries {
+		row := ImportRow{Line: entry.Line, Err: entry.Err}
+		row.ProjectTag = mapping[entry.Key()]
+		row.Project = domain.Project{Tag: row.ProjectTag, Name: entry.Project, Type: "other"}
+		if entry.Client != "" {
+			row.Project.Type = "customer"
+		}
+		row.StartTime = entry.Start
+		row.EndTime = entry.End
+		row.Name = entry.Description
+		row.Billable = entry.Billable
+		if len(entry.Tags) > 0 {
+			row.Note = "Tags: " + strings.Join(entry.Tags, ", ")
+		}
+		if row.Err == nil && row.ProjectTag == "" {
+			row.Err = fmt.Errorf("no project tag for %q", entry.Key())
+		}
+		if row.Err == nil && !row.EndTime.After(row.StartTime) {
+			row.Err = errors.New("end is not after start")
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
@@ -0,0 +1,101 @@
+package exchange
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const togglCSVFixture = "\uFEFFUser,Email,Client,Project,Description,Billable,Start date,Start time,End date,End time,Tags\n" +
+	"Anna,anna@example.com,DAG GmbH,API,review,Yes,2024-03-11,09:00:00,2024-03-11,10:30:00,\"meeting, remote\"\n" +
+	"Anna,anna@example.com,,,,No,2024-03-11,23:00:00,2024-03-12,01:00:00,\n" +
+	"Anna,anna@example.com,,Web,broken,No,2024-03-11,9 am,2024-03-11,10:00:00,\n"
+
+func TestReadTogglCSV(t *testing.T) {
+	entries, err := ReadTogglCSV(strings.NewReader(togglCSVFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(entries))
+	}
+	review := entries[0]
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+	if review.Err != nil || !review.Start.Equal(day.Add(9*time.Hour)) || !review.End.Equal(day.Add(10*time.Hour+30*time.Minute)) {
+		t.Errorf("review %v - %v, error %v", review.Start, review.End, review.Err)
+	}
+	if !review.Billable || review.Key() != "DAG GmbH / API" || len(review.Tags) != 2 || review.Tags[1] != "remote" {
+		t.Errorf("review %+v", review)
+	}
+	if key := entries[1].Key(); key != "(no project)" {
+		t.Errorf("key without project %q", key)
+	}
+	if entries[2].Err == nil {
+		t.Error("invalid start time: no error")
+	}
+
+	if _, err := ReadTogglCSV(strings.NewReader("Date,Hours\n2024-03-11,2\n")); err == nil {
+		t.Error("other CSV file: no error")
+	}
+}
+
+func TestReadTogglJSON(t *testing.T) {
+	// the time entry export, with offsets
+	entries, err := ReadTogglJSON(strings.NewReader(`[
+		{"description": "review", "start": "2024-03-11T09:00:00+02:00", "stop": "2024-03-11T10:00:00+02:00", "project_name": "API", "client_name": "DAG GmbH", "is_billable": true},
+		{"description": "running", "start": "2024-03-11T11:00:00Z", "stop": null}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+	review := entries[0]
+	if review.Err != nil || !review.Start.Equal(time.Date(2024, 3, 11, 7, 0, 0, 0, time.UTC)) || review.End.Sub(review.Start) != time.Hour {
+		t.Errorf("review %v - %v, error %v", review.Start, review.End, review.Err)
+	}
+	if review.Start.Location() != time.Local || review.End.Location() != time.Local {
+		t.Errorf("review in %v, want local time", review.Start.Location())
+	}
+	if !review.Billable || review.Key() != "DAG GmbH / API" {
+		t.Errorf("review %+v", review)
+	}
+	if entries[1].Err == nil {
+		t.Error("running entry: no error")
+	}
+
+	// the detailed report
+	entries, err = ReadTogglJSON(strings.NewReader(`{"data": [{"description": "x", "start": "2024-03-11T09:00:00Z", "end": "2024-03-11T09:30:00Z", "project": "Web"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Err != nil || entries[0].Key() != "Web" {
+		t.Errorf("report entries %+v", entries)
+	}
+
+	if _, err := ReadTogglJSON(strings.NewReader(`{"data": [`)); err == nil {
+		t.Error("malformed JSON: no error")
+	}
+}
+
+func TestTogglRows(t *testing.T) {
+	entries, err := ReadTogglCSV(strings.NewReader(togglCSVFixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := TogglRows(entries, map[string]string{"DAG GmbH / API": "DAG"})
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+	review := rows[0]
+	if review.Err != nil || review.ProjectTag != "DAG" || review.Project.Type != "customer" || review.Note != "Tags: meeting, remote" {
+		t.Errorf("review row %+v", review)
+	}
+	// unmapped, and unparsable
+	for _, row := range rows[1:] {
+		if row.Err == nil {
+			t.Errorf("line %d: no error", row.Line)
+		}
+	}
+}
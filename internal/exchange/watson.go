@@ -0,0 +1,81 @@
+package exchange
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// WriteWatson writes the rows as Watson frames, a JSON list of
+// [start, stop, project, id, tags, updated]. The project tag is the Watson
+// project and the tags hold the name and the billable tag. Watson has no
+// notes, so they are not exported.
+func WriteWatson(w io.Writer, rows []Row) error {
+	now := time.Now().Unix()
+	frames := [][]any{}
+	for _, row := range rows {
+		tags := recordingTags(row)[1:]
+		frames = append(frames, []any{
+			row.StartTime.Unix(),
+			row.EndTime.Unix(),
+			row.ProjectTag,
+			// ids are 32 hex digits, derived from the recording so that
+			// exporting again yields the same frames
+			fmt.Sprintf("%032x", row.ID),
+			tags,
+			now,
+		})
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(frames)
+}
+
+// ReadWatson reads a Watson frames file.
+func ReadWatson(r io.Reader) ([]ImportRow, error) {
+	var frames []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&frames); err != nil {
+		return nil, fmt.Errorf("not a Watson frames file: %w", err)
+	}
+	var rows []ImportRow
+	for i, frame := range frames {
+		row, err := parseWatsonFrame(frame)
+		rows = append(rows, ImportRow{Line: i + 1, Row: row, Err: err})
+	}
+	return rows, nil
+}
+
+func parseWatsonFrame(data json.RawMessage) (Row, error) {
+	row := Row{}
+	var frame []json.RawMessage
+	if err := json.Unmarshal(data, &frame); err != nil || len(frame) < 3 {
+		return row, errors.New("invalid frame")
+	}
+	var start, stop float64
+	var project string
+	var tags []string
+	if err := json.Unmarshal(frame[0], &start); err != nil {
+		return row, errors.New("invalid start")
+	}
+	if err := json.Unmarshal(frame[1], &stop); err != nil {
+		return row, errors.New("invalid stop")
+	}
+	if err := json.Unmarshal(frame[2], &project); err != nil || project == "" {
+		return row, errors.New("invalid project")
+	}
+	if len(frame) > 4 {
+		if err := json.Unmarshal(frame[4], &tags); err != nil {
+			return row, errors.New("invalid tags")
+		}
+	}
+
+	row.StartTime = time.Unix(int64(start), 0)
+	row.EndTime = time.Unix(int64(stop), 0)
+	setRecordingTags(&row, project, tags)
+	if !row.EndTime.After(row.StartTime) {
+		return row, errors.New("end is not after start")
+	}
+	return row, nil
+}
@@ -0,0 +1,76 @@
+package exchange
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+func TestReadWatson(t *testing.T) {
+	input := `[
+		[1710144000, 1710149400, "DAG", "0123", ["review", "billable"], 1710149400],
+		[1710144000, 1710147600, "WEB"],
+		[1710144000, 1710140400, "DAG", "4567", []],
+		[1710144000, 1710147600, ""],
+		["9:00", 1710147600, "DAG"],
+		[1710144000, 1710147600, "DAG", "89ab", "review"],
+		"frame"
+	]`
+	rows, err := ReadWatson(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 7 {
+		t.Fatalf("got %d rows, want 7", len(rows))
+	}
+	review := rows[0]
+	if review.Err != nil || review.ProjectTag != "DAG" || review.Name != "review" || !review.Billable {
+		t.Errorf("review %+v, error %v", review.Row, review.Err)
+	}
+	if !review.StartTime.Equal(time.Date(2024, 3, 11, 8, 0, 0, 0, time.UTC)) || review.Duration() != 90*time.Minute {
+		t.Errorf("review %v - %v", review.StartTime, review.EndTime)
+	}
+	if review.StartTime.Location() != time.Local {
+		t.Errorf("review in %v, want local time", review.StartTime.Location())
+	}
+	if web := rows[1]; web.Err != nil || web.Name != "" {
+		t.Errorf("frame without tags %+v, error %v", web.Row, web.Err)
+	}
+	// backwards, no project, invalid start, invalid tags, not a frame
+	for _, row := range rows[2:] {
+		if row.Err == nil {
+			t.Errorf("frame %d: no error", row.Line)
+		}
+	}
+
+	if _, err := ReadWatson(strings.NewReader(`{"frames": []}`)); err == nil {
+		t.Error("not a list: no error")
+	}
+}
+
+func TestWatsonRoundTrip(t *testing.T) {
+	start := time.Date(2024, 3, 11, 9, 0, 0, 0, time.Local)
+	want := Row{Recording: domain.Recording{ID: 7, ProjectTag: "DAG", StartTime: start, EndTime: start.Add(time.Hour), Name: "review", Billable: true}}
+	var buf bytes.Buffer
+	if err := WriteWatson(&buf, []Row{want}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"00000000000000000000000000000007"`) {
+		t.Errorf("frame id missing in %s", buf.String())
+	}
+	rows, err := ReadWatson(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0].Err != nil {
+		t.Fatalf("read back %+v", rows)
+	}
+	got := rows[0]
+	if got.ProjectTag != want.ProjectTag || got.Name != want.Name || got.Billable != want.Billable ||
+		!got.StartTime.Equal(want.StartTime) || !got.EndTime.Equal(want.EndTime) {
+		t.Errorf("read back %+v, want %+v", got.Row, want)
+	}
+}
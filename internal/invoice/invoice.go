@@ -0,0 +1,116 @@
+// Package invoice renders invoices from templates.
+package invoice
+
+import (
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+// Formats are the formats invoices are rendered in, by file extension.
+var Formats = []string{"html", "md"}
+
+//go:embed templates
+var templates embed.FS
+
+// Document is the data passed to the templates.
+type Document struct {
+	domain.Invoice
+	Project domain.Project
+	// Client is the addressee, nil if the project has no client.
+	Client *domain.Client
+	// Issuer is the invoicing party, from the invoiceIssuer setting.
+	Issuer string
+	Hours  float64
+}
+
+// Lines bills the recordings at their hourly rates. Amounts are rounded to
+// cents per line; the total is their sum.
+func Lines(project domain.Project, recordings []domain.Recording) (lines []domain.InvoiceLine, hours, total float64) {
+	for _, recording := range recordings {
+		line := domain.InvoiceLine{
+			Date:  recording.StartTime,
+			Name:  recording.Name,
+			Hours: math.Round(recording.Duration().Hours()*100) / 100,
+			Rate:  recording.Rate(project),
+		}
+		line.Amount = math.Round(line.Hours*line.Rate*100) / 100
+		lines = append(lines, line)
+		hours += line.Hours
+		total += line.Amount
+	}
+	return lines, hours, math.Round(total*100) / 100
+}
+
+// NewDocument prepares an invoice and its lines for rendering. The client
+// may be nil.
+func NewDocument(invoice domain.Invoice, project domain.Project, client *domain.Client, issuer string) *Document {
+	doc := &Document{Invoice: invoice, Project: project, Client: client, Issuer: issuer}
+	for _, line := range invoice.Lines {
+		doc.Hours += line.Hours
+	}
+	return doc
+}
+
+var funcs = map[string]any{
+	"date": func(t time.Time) string {
+		return t.Format("02.01.2006")
+	},
+	"money": func(amount float64) string {
+		return fmt.Sprintf("%.2f", amount)
+	},
+	"hours": func(hours float64) string {
+		return fmt.Sprintf("%.2f", hours)
+	},
+	// lines splits multi-line text like addresses
+	"lines": func(text string) []string {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(text), "\n")
+	},
+	// lastDay turns the exclusive end of the period into its last day
+	"lastDay": func(t time.Time) time.Time {
+		return t.AddDate(0, 0, -1)
+	},
+}
+
+// Render writes the document in the given format. A file invoice.<format>.tmpl
+// in templateDir replaces the built-in template.
+func Render(w io.Writer, format string, doc *Document, templateDir string) error {
+	name := "invoice." + format + ".tmpl"
+	text, err := templates.ReadFile("templates/" + name)
+	if err != nil {
+		return fmt.Errorf("unknown invoice format %q", format)
+	}
+	if templateDir != "" {
+		custom, err := os.ReadFile(filepath.Join(templateDir, name))
+		if err == nil {
+			text = custom
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if format == "html" {
+		tmpl, err := htmltemplate.New(name).Funcs(funcs).Parse(string(text))
+		if err != nil {
+			return err
+		}
+		return tmpl.Execute(w, doc)
+	}
+	tmpl, err := template.New(name).Funcs(funcs).Parse(string(text))
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(w, doc)
+}
@@ -0,0 +1,81 @@
+package report
+
+import (
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/utils"
+)
+
+// BudgetPeriodRange returns the budget period [from, to) containing t. Total
+// budgets have no period and return zero times.
+func BudgetPeriodRange(period string, t time.Time) (from, to time.Time) {
+	var last time.Time
+	switch period {
+	case domain.BudgetWeek:
+		year, week := t.ISOWeek()
+		from, last = utils.WeekRange(year, week)
+	case domain.BudgetMonth:
+		from, last = utils.MonthRange(t.Year(), t.Month())
+	case domain.BudgetQuarter:
+		from, last = utils.QuarterRange(t.Year(), (int(t.Month())-1)/3+1)
+	case domain.BudgetYear:
+		from, last = utils.YearRange(t.Year())
+	default:
+		return time.Time{}, time.Time{}
+	}
+	return from, last.AddDate(0, 0, 1)
+}
+
+// BudgetUsage is how much of a project's budget has been used in the
+// current period.
+type BudgetUsage struct {
+	From     time.Time
+	To       time.Time
+	Duration time.Duration
+	// Amount is the billable time charged at the hourly rates.
+	Amount float64
+}
+
+// Budget sums up the recordings of a project in the budget period around
+// now. Running recordings count until now.
+func Budget(project domain.Project, recordings []domain.Recording, now time.Time) BudgetUsage {
+	usage := BudgetUsage{}
+	usage.From, usage.To = BudgetPeriodRange(project.BudgetPeriod, now)
+	for _, recording := range recordings {
+		if recording.ProjectTag != project.Tag {
+			continue
+		}
+		start, end := recording.StartTime, recording.EndTime
+		if recording.IsRunning() {
+			end = now
+		}
+		if !usage.From.IsZero() && start.Before(usage.From) {
+			start = usage.From
+		}
+		if !usage.To.IsZero() && end.After(usage.To) {
+			end = usage.To
+		}
+		if !end.After(start) {
+			continue
+		}
+		usage.Duration += end.Sub(start)
+		if recording.Billable {
+			usage.Amount += end.Sub(start).Hours() * recording.Rate(project)
+		}
+	}
+	return usage
+}
+
+// Share returns the used share of the budget, the larger one of hours and
+// amount if both are limited. It is 0 for projects without a budget.
+func (u BudgetUsage) Share(project domain.Project) float64 {
+	share := 0.0
+	if project.BudgetHours > 0 {
+		share = u.Duration.Hours() / project.BudgetHours
+	}
+	if project.BudgetAmount > 0 && u.Amount/project.BudgetAmount > share {
+		share = u.Amount / project.BudgetAmount
+	}
+	return share
+}
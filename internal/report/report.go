@@ -0,0 +1,177 @@
+// Package report sums up recordings over a time range.
+package report
+
+import (
+	"sort"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/utils"
+)
+
+// Total is the time booked on one key of a grouping.
+type Total struct {
+	Key      string
+	Duration time.Duration
+	Billable time.Duration
+	// Amounts is the billable time charged at the hourly rates, by
+	// currency. Amounts in different currencies are never added up.
+	Amounts map[string]float64
+}
+
+// NonBillable returns the time that is not billable.
+func (t Total) NonBillable() time.Duration {
+	return t.Duration - t.Billable
+}
+
+// Summary holds the totals of the recordings in [From, To).
+type Summary struct {
+	From time.Time
+	To   time.Time
+	Total
+	// ByClient is keyed by client name, NoClient collects projects without
+	// a client.
+	ByClient  []Total
+	ByProject []Total
+	ByType    []Total
+	// ByLabel is keyed by label, NoLabel collects recordings without labels.
+	// Recordings with several labels count for each of them, so the label
+	// totals may add up to more than the total.
+	ByLabel []Total
+	// ByDay is keyed by date (utils.DateLayout) and lists every day of the
+	// range, including days without recordings.
+	ByDay []Total
+}
+
+// NoClient is the client key of projects without a client.
+const NoClient = "(no client)"
+
+// NoLabel is the label key of recordings without labels.
+const NoLabel = "(no label)"
+
+// Currencies returns the currencies of the amounts, sorted.
+func (t Total) Currencies() []string {
+	currencies := make([]string, 0, len(t.Amounts))
+	for currency := range t.Amounts {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// Summarize sums up the recordings. Only the part of a recording within the
+// range counts, running recordings count until now. Amounts are in the
+// currency of the project's client, or in the given currency for projects
+// without a client or clients without a currency.
+func Summarize(recordings []domain.Recording, projects []domain.Project, clients []domain.Client, currency string, from, to time.Time) *Summary {
+	byTag := map[string]domain.Project{}
+	for _, project := range projects {
+		byTag[project.Tag] = project
+	}
+	clientNames := map[int64]string{}
+	clientCurrencies := map[int64]string{}
+	for _, client := range clients {
+		clientNames[client.ID] = client.Name
+		clientCurrencies[client.ID] = client.Currency
+	}
+
+	summary := &Summary{From: from, To: to}
+	byClient := newGrouping()
+	byProject := newGrouping()
+	byType := newGrouping()
+	byLabel := newGrouping()
+	byDay := newGrouping()
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		byDay.add(day.Format(utils.DateLayout), 0, false, amount{})
+	}
+
+	now := time.Now()
+	for _, recording := range recordings {
+		start, end := recording.StartTime, recording.EndTime
+		if recording.IsRunning() {
+			end = now
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+		project := byTag[recording.ProjectTag]
+		client, ok := clientNames[project.ClientID]
+		if !ok {
+			client = NoClient
+		}
+		charge := amount{currency: currency, rate: recording.Rate(project)}
+		if clientCurrency := clientCurrencies[project.ClientID]; clientCurrency != "" {
+			charge.currency = clientCurrency
+		}
+		labels := recording.Labels
+		if len(labels) == 0 {
+			labels = []string{NoLabel}
+		}
+		for _, span := range utils.SplitByDay(start, end) {
+			duration := span.End.Sub(span.Start)
+			summary.add(duration, recording.Billable, charge)
+			byClient.add(client, duration, recording.Billable, charge)
+			byProject.add(recording.ProjectTag, duration, recording.Billable, charge)
+			byType.add(project.Type, duration, recording.Billable, charge)
+			for _, label := range labels {
+				byLabel.add(label, duration, recording.Billable, charge)
+			}
+			byDay.add(span.Start.Format(utils.DateLayout), duration, recording.Billable, charge)
+		}
+	}
+
+	summary.ByClient = byClient.totals()
+	summary.ByProject = byProject.totals()
+	summary.ByType = byType.totals()
+	summary.ByLabel = byLabel.totals()
+	summary.ByDay = byDay.totals()
+	return summary
+}
+
+// amount is the hourly rate of a recording and the currency it is in.
+type amount struct {
+	currency string
+	rate     float64
+}
+
+func (t *Total) add(duration time.Duration, billable bool, charge amount) {
+	t.Duration += duration
+	if billable {
+		t.Billable += duration
+		if t.Amounts == nil {
+			t.Amounts = map[string]float64{}
+		}
+		t.Amounts[charge.currency] += duration.Hours() * charge.rate
+	}
+}
+
+// grouping sums up durations by key.
+type grouping map[string]*Total
+
+func newGrouping() grouping {
+	return grouping{}
+}
+
+func (g grouping) add(key string, duration time.Duration, billable bool, charge amount) {
+	total, ok := g[key]
+	if !ok {
+		total = &Total{Key: key}
+		g[key] = total
+	}
+	total.add(duration, billable, charge)
+}
+
+// totals returns the totals sorted by key.
+func (g grouping) totals() []Total {
+	totals := make([]Total, 0, len(g))
+	for _, total := range g {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		return totals[i].Key < totals[j].Key
+	})
+	return totals
+}
@@ -0,0 +1,46 @@
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+func TestSummarizeCurrencies(t *testing.T) {
+	day := time.Date(2024, 3, 11, 0, 0, 0, 0, time.Local)
+	clients := []domain.Client{{ID: 1, Name: "Rated Corp", Currency: "USD"}, {ID: 2, Name: "Local GmbH"}}
+	projects := []domain.Project{
+		{Tag: "US", Type: "customer", ClientID: 1, HourlyRate: 100},
+		{Tag: "AT", Type: "customer", ClientID: 2, HourlyRate: 80},
+		{Tag: "OWN", Type: "internal", HourlyRate: 50},
+	}
+	recordings := []domain.Recording{
+		{ProjectTag: "US", StartTime: day.Add(8 * time.Hour), EndTime: day.Add(10 * time.Hour), Billable: true},
+		{ProjectTag: "AT", StartTime: day.Add(10 * time.Hour), EndTime: day.Add(11 * time.Hour), Billable: true},
+		{ProjectTag: "OWN", StartTime: day.Add(11 * time.Hour), EndTime: day.Add(12 * time.Hour), Billable: true},
+		{ProjectTag: "OWN", StartTime: day.Add(12 * time.Hour), EndTime: day.Add(13 * time.Hour)},
+	}
+	summary := Summarize(recordings, projects, clients, "EUR", day, day.AddDate(0, 0, 1))
+
+	if want := map[string]float64{"EUR": 130, "USD": 200}; !reflect.DeepEqual(summary.Amounts, want) {
+		t.Errorf("total amounts %v, want %v", summary.Amounts, want)
+	}
+	if summary.Duration != 5*time.Hour || summary.Billable != 4*time.Hour {
+		t.Errorf("total %v, billable %v", summary.Duration, summary.Billable)
+	}
+	if currencies := summary.Currencies(); !reflect.DeepEqual(currencies, []string{"EUR", "USD"}) {
+		t.Errorf("currencies %v", currencies)
+	}
+	for _, total := range summary.ByClient {
+		if total.Key == "Rated Corp" && !reflect.DeepEqual(total.Amounts, map[string]float64{"USD": 200}) {
+			t.Errorf("Rated Corp amounts %v", total.Amounts)
+		}
+	}
+	for _, total := range summary.ByProject {
+		if total.Key == "OWN" && !reflect.DeepEqual(total.Amounts, map[string]float64{"EUR": 50}) {
+			t.Errorf("OWN amounts %v", total.Amounts)
+		}
+	}
+}
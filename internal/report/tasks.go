@@ -0,0 +1,44 @@
+package report
+
+import (
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+)
+
+// TaskTotal is the time booked on a task, to be compared with its estimate.
+type TaskTotal struct {
+	domain.Task
+	Actual time.Duration
+}
+
+// Remaining returns the estimated time left, negative once the estimate is
+// exceeded.
+func (t TaskTotal) Remaining() time.Duration {
+	return t.Estimate - t.Actual
+}
+
+// Progress returns the booked time as a share of the estimate, 0 for tasks
+// without an estimate.
+func (t TaskTotal) Progress() float64 {
+	if t.Estimate == 0 {
+		return 0
+	}
+	return float64(t.Actual) / float64(t.Estimate)
+}
+
+// SummarizeTasks sums up the recordings booked on each task, running
+// recordings count until now. The totals are in the order of the tasks.
+func SummarizeTasks(tasks []domain.Task, recordings []domain.Recording) []TaskTotal {
+	actual := map[int64]time.Duration{}
+	for _, recording := range recordings {
+		if recording.TaskID != 0 {
+			actual[recording.TaskID] += recording.Duration()
+		}
+	}
+	totals := make([]TaskTotal, 0, len(tasks))
+	for _, task := range tasks {
+		totals = append(totals, TaskTotal{Task: task, Actual: actual[task.ID]})
+	}
+	return totals
+}
@@ -1,6 +1,9 @@
 package utils
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 func WeekRange(year, week int) (start, end time.Time) {
 	start = WeekStart(year, week)
@@ -25,3 +28,74 @@ func WeekStart(year, week int) time.Time {
 
 	return t
 }
+
+// MonthRange returns the first and the last day of a month.
+func MonthRange(year int, month time.Month) (start, end time.Time) {
+	start = time.Date(year, month, 1, 0, 0, 0, 0, time.Local)
+	end = start.AddDate(0, 1, -1)
+	return
+}
+
+// QuarterRange returns the first and the last day of a quarter (1-4).
+func QuarterRange(year, quarter int) (start, end time.Time) {
+	start = time.Date(year, time.Month(quarter-1)*3+1, 1, 0, 0, 0, 0, time.Local)
+	end = start.AddDate(0, 3, -1)
+	return
+}
+
+// YearRange returns the first and the last day of a year.
+func YearRange(year int) (start, end time.Time) {
+	start = time.Date(year, time.January, 1, 0, 0, 0, 0, time.Local)
+	end = time.Date(year, time.December, 31, 0, 0, 0, 0, time.Local)
+	return
+}
+
+// FormatDuration formats a duration as hours and minutes, e.g. "02:05".
+func FormatDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	h := d / time.Hour
+	m := (d - h*time.Hour) / time.Minute
+	return fmt.Sprintf("%02d:%02d", h, m)
+}
+
+// DayStart returns midnight of the day t falls on.
+func DayStart(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Span is a time interval [Start, End).
+type Span struct {
+	Start time.Time
+	End   time.Time
+}
+
+// SplitByDay splits [start, end) at every midnight, so each span lies within
+// a single day.
+func SplitByDay(start, end time.Time) []Span {
+	var spans []Span
+	for start.Before(end) {
+		next := DayStart(start).AddDate(0, 0, 1)
+		if next.After(end) {
+			next = end
+		}
+		spans = append(spans, Span{Start: start, End: next})
+		start = next
+	}
+	return spans
+}
+
+const (
+	DateLayout     = "2006-01-02"
+	DateTimeLayout = "2006-01-02 15:04"
+)
+
+// ParseDate parses a date like "2024-03-01" in the local time zone.
+func ParseDate(s string) (time.Time, error) {
+	return time.ParseInLocation(DateLayout, s, time.Local)
+}
+
+// ParseDateTime parses a date and time like "2024-03-01 09:30" in the local
+// time zone.
+func ParseDateTime(s string) (time.Time, error) {
+	return time.ParseInLocation(DateTimeLayout, s, time.Local)
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/invoice"
+	"downardo.at/timetracking/internal/utils"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/viper"
+)
+
+func cmdInvoice(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing invoice command", errUsage)
+	}
+	switch args[0] {
+	case "create":
+		return invoiceCreate(repo, args[1:])
+	case "list":
+		return invoiceList(repo, args[1:])
+	case "show":
+		return invoiceShow(repo, args[1:])
+	default:
+		return fmt.Errorf("%w: unknown invoice command %q", errUsage, args[0])
+	}
+}
+
+// invoiceCreate bills the uninvoiced billable recordings of a customer
+// project that start in the period, and writes the invoice as HTML and
+// Markdown. Recordings without duration are left out. If a file can't be
+// written after the invoice is stored, the error names the invoice so it can
+// be written again with invoice show.
+func invoiceCreate(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("invoice create", flag.ContinueOnError)
+	from := fs.String("from", "", "first day ("+utils.DateLayout+")")
+	to := fs.String("to", "", "last day ("+utils.DateLayout+")")
+	outputDir := fs.String("output-dir", ".", "directory for the invoice files")
+	dryRun := fs.Bool("dry-run", false, "only show what would be invoiced")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%w: tag is required", errUsage)
+	}
+	start, err := utils.ParseDate(*from)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --from date %q", errUsage, *from)
+	}
+	last, err := utils.ParseDate(*to)
+	if err != nil {
+		return fmt.Errorf("%w: invalid --to date %q", errUsage, *to)
+	}
+	end := last.AddDate(0, 0, 1)
+	if !end.After(start) {
+		return fmt.Errorf("%w: --to is before --from", errUsage)
+	}
+
+	project, err := repo.GetProjectByTag(rest[0])
+	if errors.Is(err, domain.ErrNotExists) {
+		return fmt.Errorf("project %q not found", rest[0])
+	} else if err != nil {
+		return err
+	}
+	if project.Type != "customer" {
+		return fmt.Errorf("project %s is not a customer project", project.Tag)
+	}
+	client, err := projectClient(repo, *project)
+	if err != nil {
+		return err
+	}
+	currency := viper.GetString("currency")
+	if client != nil && client.Currency != "" {
+		currency = client.Currency
+	}
+
+	billable := true
+	found, err := repo.FindRecordings(domain.RecordingFilter{ProjectTag: project.Tag, From: start, To: end, Billable: &billable, Uninvoiced: true})
+	if err != nil {
+		return err
+	}
+	var recordings []domain.Recording
+	var ids []int64
+	for _, recording := range found {
+		if recording.IsRunning() || recording.StartTime.Before(start) || recording.Duration() == 0 {
+			continue
+		}
+		recordings = append(recordings, recording)
+		ids = append(ids, recording.ID)
+	}
+	if len(recordings) == 0 {
+		return fmt.Errorf("no uninvoiced billable recordings of %s in this period", project.Tag)
+	}
+
+	lines, hours, total := invoice.Lines(*project, recordings)
+	printInvoiceLines(lines, hours, total, currency)
+	for _, line := range lines {
+		if line.Rate == 0 {
+			Info("Some recordings have no hourly rate")
+			break
+		}
+	}
+	if *dryRun {
+		return nil
+	}
+
+	draft := domain.Invoice{
+		ProjectTag: project.Tag,
+		IssuedAt:   time.Now(),
+		From:       start,
+		To:         end,
+		Currency:   currency,
+		Amount:     total,
+		Lines:      lines,
+	}
+	// the files are rendered once before the invoice is stored, a broken
+	// template or output directory shouldn't leave an invoice without files
+	if info, err := os.Stat(*outputDir); err != nil {
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", *outputDir)
+	}
+	for _, format := range invoice.Formats {
+		doc := invoice.NewDocument(draft, *project, client, viper.GetString("invoiceIssuer"))
+		if err := invoice.Render(io.Discard, format, doc, viper.GetString("invoiceTemplateDir")); err != nil {
+			return err
+		}
+	}
+
+	created, err := repo.CreateInvoice(draft, ids)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created invoice %d over %.2f %s\n", created.Number, created.Amount, created.Currency)
+
+	doc := invoice.NewDocument(*created, *project, client, viper.GetString("invoiceIssuer"))
+	for _, format := range invoice.Formats {
+		path := filepath.Join(*outputDir, fmt.Sprintf("invoice-%d.%s", created.Number, format))
+		if err := writeInvoice(path, format, doc); err != nil {
+			return fmt.Errorf("invoice %d is stored but %s was not written, write it with \"invoice show --format %s --output %s %d\": %w", created.Number, path, format, path, created.Number, err)
+		}
+		fmt.Println("Wrote " + path)
+	}
+	return nil
+}
+
+func printInvoiceLines(lines []domain.InvoiceLine, hours, total float64, currency string) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Date", "Name", "Hours", "Rate", "Amount"})
+	for _, line := range lines {
+		t.AppendRow(table.Row{line.Date.Format(utils.DateLayout), line.Name, fmt.Sprintf("%.2f", line.Hours), rateString(line.Rate), fmt.Sprintf("%.2f", line.Amount)})
+	}
+	t.AppendFooter(table.Row{"", "Total", fmt.Sprintf("%.2f", hours), "", fmt.Sprintf("%.2f %s", total, currency)})
+	t.SetStyle(table.StyleLight)
+	t.Render()
+}
+
+func writeInvoice(path string, format string, doc *invoice.Document) error {
+	out, err := createOutput(path)
+	if err != nil {
+		return err
+	}
+	if err := invoice.Render(out, format, doc, viper.GetString("invoiceTemplateDir")); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+func invoiceList(repo domain.Repository, args []string) error {
+	if len(args) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, args[0])
+	}
+	invoices, err := repo.AllInvoices()
+	if err != nil {
+		return err
+	}
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Number", "Tag", "Date", "From", "To", "Amount"})
+	for _, invoice := range invoices {
+		t.AppendRow(table.Row{invoice.Number, invoice.ProjectTag, invoice.IssuedAt.Format(utils.DateLayout), invoice.From.Format(utils.DateLayout), invoice.To.AddDate(0, 0, -1).Format(utils.DateLayout), fmt.Sprintf("%.2f %s", invoice.Amount, invoice.Currency)})
+	}
+	t.SetStyle(table.StyleLight)
+	t.Render()
+	return nil
+}
+
+// invoiceShow renders a stored invoice again.
+func invoiceShow(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("invoice show", flag.ContinueOnError)
+	format := fs.String("format", "md", "html or md")
+	output := fs.String("output", "", "output file, stdout if empty")
+	rest, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(rest) != 1 {
+		return fmt.Errorf("%w: invoice number is required", errUsage)
+	}
+	number, err := strconv.ParseInt(rest[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid invoice number %q", errUsage, rest[0])
+	}
+	if *format != "html" && *format != "md" {
+		return fmt.Errorf("%w: unknown format %q", errUsage, *format)
+	}
+
+	stored, err := repo.GetInvoice(number)
+	if errors.Is(err, domain.ErrNotExists) {
+		return fmt.Errorf("invoice %d not found", number)
+	} else if err != nil {
+		return err
+	}
+	project, err := repo.GetProjectByTag(stored.ProjectTag)
+	if err != nil {
+		return err
+	}
+	client, err := projectClient(repo, *project)
+	if err != nil {
+		return err
+	}
+	if len(stored.Lines) == 0 {
+		// invoices from before lines were stored are billed at today's rates
+		recordings, err := repo.GetRecordingsByInvoice(number)
+		if err != nil {
+			return err
+		}
+		stored.Lines, _, _ = invoice.Lines(*project, recordings)
+	}
+	return writeInvoice(*output, *format, invoice.NewDocument(*stored, *project, client, viper.GetString("invoiceIssuer")))
+}
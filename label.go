@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"downardo.at/timetracking/internal/domain"
+	"github.com/charmbracelet/huh"
+)
+
+// normalizeLabel turns "#Meeting" or "meeting" into "meeting". Labels consist
+// of letters, digits, "-" and "_".
+func normalizeLabel(str string) (string, error) {
+	label := strings.ToLower(strings.TrimPrefix(str, "#"))
+	if label == "" || utf8.RuneCountInString(label) > 30 {
+		return "", fmt.Errorf("invalid label %q, use up to 30 letters, digits, - or _", str)
+	}
+	for _, c := range label {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '-' && c != '_' {
+			return "", fmt.Errorf("invalid label %q, use up to 30 letters, digits, - or _", str)
+		}
+	}
+	return label, nil
+}
+
+// parseLabels splits the #label words off a recording name, so
+// "review #meeting" is named "review" and labeled "meeting". The labels are
+// returned sorted and without duplicates.
+func parseLabels(text string) (name string, labels []string, err error) {
+	var words []string
+	for _, word := range strings.Fields(text) {
+		if len(word) < 2 || !strings.HasPrefix(word, "#") {
+			words = append(words, word)
+			continue
+		}
+		label, err := normalizeLabel(word)
+		if err != nil {
+			return "", nil, err
+		}
+		if !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	slices.Sort(labels)
+	return strings.Join(words, " "), labels, nil
+}
+
+// labelsString formats labels the way they are entered, like "#meeting #travel".
+func labelsString(labels []string) string {
+	var words []string
+	for _, label := range labels {
+		words = append(words, "#"+label)
+	}
+	return strings.Join(words, " ")
+}
+
+// nameWithLabels is the name of a recording followed by its labels, for
+// forms that edit both in one input.
+func nameWithLabels(recording domain.Recording) string {
+	if len(recording.Labels) == 0 {
+		return recording.Name
+	}
+	return recording.Name + " " + labelsString(recording.Labels)
+}
+
+// validateNameWithLabels requires a name besides valid labels.
+func validateNameWithLabels(str string) error {
+	name, _, err := parseLabels(str)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return errors.New("please enter a name.")
+	}
+	if utf8.RuneCountInString(name) > 70 {
+		return errors.New("the name is limited to 70 characters")
+	}
+	return nil
+}
+
+// labelOptions lists the labels in use for selection, starting with "Any".
+func labelOptions(repo domain.Repository) []huh.Option[string] {
+	labels, err := repo.AllLabels()
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := []huh.Option[string]{huh.NewOption("Any label", "")}
+	for _, label := range labels {
+		options = append(options, huh.NewOption("#"+label, label))
+	}
+	return options
+}
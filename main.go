@@ -5,22 +5,27 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/utils"
 	"github.com/charmbracelet/huh"
 	"github.com/fatih/color"
 	"github.com/jedib0t/go-pretty/v6/table"
+	_ "github.com/lib/pq"
 	"github.com/spf13/viper"
 	_ "modernc.org/sqlite"
 )
 
 const VERSION = "0.0.1"
 
-var TrackingRepositroy *domain.SQLiteRepository
+var TrackingRepositroy domain.Repository
 
 func initConfig() {
 	// Setting up some configurations
@@ -32,6 +37,11 @@ func initConfig() {
 
 	viper.ReadInConfig() // Find and read the config file
 
+	// currency of hourly rates and amounts
+	viper.SetDefault("currency", "EUR")
+	// percentage of a project budget above which starting a recording warns
+	viper.SetDefault("budgetWarning", 80)
+
 	// set default values if they are unset
 	if viper.GetString("databaseDriver") == "" {
 		viper.SetDefault("databaseDriver", "sqlite")
@@ -40,25 +50,84 @@ func initConfig() {
 		if err := viper.WriteConfigAs("app_config.yaml"); err != nil {
 			log.Fatal(err)
 		}
+		log.Print("Configuration file created/updated successfully!")
+	}
+}
+
+// openDatabase opens the storage selected by the databaseDriver setting:
+// "sqlite" (databaseFile), "postgres" (databaseURL) or "memory".
+func openDatabase() domain.Repository {
+	switch driver := viper.GetString("databaseDriver"); driver {
+	case "sqlite":
+		db, err := sql.Open("sqlite", viper.GetString("databaseFile")+"?_pragma=foreign_keys(1)")
+		if err != nil {
+			log.Fatal(err)
+		}
+		return domain.NewSQLiteRepository(db)
+	case "postgres":
+		db, err := sql.Open("postgres", viper.GetString("databaseURL"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		return domain.NewPostgresRepository(db)
+	case "memory":
+		return domain.NewMemoryRepository()
+	default:
+		log.Fatalf("unknown database driver %q", driver)
 	}
-	log.Print("Configuration file created/updated successfully!")
+	return nil
 }
 
-func initDatabase() *domain.SQLiteRepository {
-	db, err := sql.Open(viper.GetString("databaseDriver"), viper.GetString("databaseFile"))
+// initDatabase opens the storage and applies pending migrations. An existing
+// SQLite file is backed up before it is migrated.
+func initDatabase() domain.Repository {
+	file := viper.GetString("databaseFile")
+	_, statErr := os.Stat(file)
+	existed := viper.GetString("databaseDriver") == "sqlite" && statErr == nil
+
+	trackingRepositroy := openDatabase()
+
+	pending, err := domain.PendingMigrations(trackingRepositroy)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if len(pending) > 0 && existed {
+		backup, err := backupDatabaseFile(file)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Database backed up to %s before migrating", backup)
+	}
 
 	// Migrate the database
-	trackingRepositroy := domain.NewSQLiteRepository(db)
-
 	if err := trackingRepositroy.Migrate(); err != nil {
 		log.Fatal(err)
 	}
 	return trackingRepositroy
 }
 
+// backupDatabaseFile copies the database file next to itself and returns the
+// path of the copy.
+func backupDatabaseFile(file string) (string, error) {
+	backup := file + "." + time.Now().Format("20060102-150405") + ".bak"
+
+	src, err := os.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(backup, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", err
+	}
+	return backup, dst.Close()
+}
+
 func Info(a ...interface{}) (int, error) {
 	return color.New(color.FgWhite, color.Bold).Println(a...)
 }
@@ -83,48 +152,316 @@ func pressEnterToContinue() {
 	bufio.NewReader(os.Stdin).ReadBytes('\n')
 }
 
-func printTopBar() {
+func printTopBar(repo domain.Repository) {
 	Info("=================================")
 	tn := time.Now()
 	year, week := tn.ISOWeek()
 	Info("Time Tracking Week: ", week, " Year: ", year)
 	//Get today's date
 
+	running, err := repo.GetRunningRecording()
+	if err != nil && !errors.Is(err, domain.ErrNotExists) {
+		log.Fatal(err)
+	}
+	if running != nil {
+		Notice("Running: ", running.ProjectTag, " - ", running.Name, " (", utils.FormatDuration(running.Duration()), ")")
+	}
+}
+
+// startRecording stops the running recording, if there is one, and starts a
+// new recording for the given project. Only one recording may run at a time.
+// A recording booked on a task is named after it unless a name is given.
+func startRecording(repo domain.Repository, tag string, name string, taskID int64, labels []string) (started *domain.Recording, stopped *domain.Recording, err error) {
+	project, err := repo.GetProjectByTag(tag)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !project.Status.Trackable() {
+		return nil, nil, domain.ErrProjectClosed
+	}
+	if taskID != 0 {
+		task, err := repo.GetTask(taskID)
+		if err != nil {
+			return nil, nil, err
+		}
+		if task.ProjectTag != project.Tag {
+			return nil, nil, domain.ErrTaskProject
+		}
+		if name == "" {
+			name = task.Name
+		}
+	}
+
+	billable, err := billableDefault(repo, project.Type)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	stopped, err = stopRecording(repo)
+	if err != nil && !errors.Is(err, domain.ErrNotExists) {
+		return nil, nil, err
+	}
+
+	started, err = repo.CreateRecording(domain.Recording{
+		ProjectTag: project.Tag,
+		StartTime:  time.Now(),
+		Name:       name,
+		Billable:   billable,
+		Status:     0,
+		TaskID:     taskID,
+		Labels:     labels,
+	})
+	if err != nil {
+		return nil, stopped, err
+	}
+	return started, stopped, nil
 }
 
-func printProjectList(repo *domain.SQLiteRepository, onlyActive bool) {
+// stopRecording closes the running recording and returns it. Databases of
+// older versions may hold several running recordings, they are all closed and
+// the latest one is returned. It returns domain.ErrNotExists if nothing is
+// running.
+func stopRecording(repo domain.Repository) (*domain.Recording, error) {
+	var latest *domain.Recording
+	for {
+		running, err := repo.GetRunningRecording()
+		if errors.Is(err, domain.ErrNotExists) && latest != nil {
+			return latest, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		running.EndTime = time.Now()
+		stopped, err := repo.UpdateRecording(running.ID, *running)
+		if err != nil {
+			return nil, err
+		}
+		if latest == nil {
+			latest = stopped
+		}
+	}
+}
+
+// printProjectList lists the projects in the given state, or all projects if
+// status is nil.
+func printProjectList(repo domain.Repository, status *domain.ProjectStatus) {
 	clearTerminal()
 
 	projects, err := repo.AllProjects()
-	if onlyActive {
-		projects, err = repo.AllActiveProjects()
+	if err != nil {
+		log.Fatal(err)
 	}
+	clients, err := clientNames(repo)
 	if err != nil {
 		log.Fatal(err)
 	}
-	if onlyActive {
-		Notice("Project List - Active Projects")
-	} else {
+	if status == nil {
 		Notice("Project List - All Projects")
+	} else {
+		projects = projectsWithStatus(projects, *status)
+		Notice("Project List - Projects " + status.String())
 	}
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
-	t.AppendHeader(table.Row{"Tag", "Name", "Type", "Status"})
+	t.AppendHeader(table.Row{"Tag", "Name", "Type", "Client", "Status", "Rate", "Budget"})
 
+	types, err := projectTypes(repo)
+	if err != nil {
+		log.Fatal(err)
+	}
 	for _, project := range projects {
-		t.AppendRow([]interface{}{project.Tag, project.Name, project.Type, project.StatusString()})
+		budget, err := budgetString(repo, project)
+		if err != nil {
+			log.Fatal(err)
+		}
+		t.AppendRow([]interface{}{project.Tag, project.Name, typeString(types, project.Type), clients[project.ClientID], project.Status.String(), rateString(project.HourlyRate), budget})
 	}
 	t.SetStyle(table.StyleDouble)
 	t.Render()
-	Info("Available commands: [new, edit (tag), delete (tag), all, active, exit] [tag]")
+	Info("Available commands: [new, edit (tag), delete (tag), all, planned, active, on hold, completed, archived, exit] [tag]")
+	InputPrint()
+}
+
+// parseWeekArgs parses the optional "(week)" or "(year) (week)" arguments of
+// the week command, defaulting to the current ISO week.
+func parseWeekArgs(args []string) (year int, week int, err error) {
+	year, week = time.Now().ISOWeek()
+	switch len(args) {
+	case 0:
+		return year, week, nil
+	case 1:
+		week, err = strconv.Atoi(args[0])
+	case 2:
+		if year, err = strconv.Atoi(args[0]); err == nil {
+			week, err = strconv.Atoi(args[1])
+		}
+	default:
+		return 0, 0, errors.New("usage: week [year] [week]")
+	}
+	if err != nil {
+		return 0, 0, errors.New("week and year have to be numbers")
+	}
+	if week < 1 || week > 53 {
+		return 0, 0, errors.New("week has to be between 1 and 53")
+	}
+	return year, week, nil
+}
+
+// printWeek renders all recordings of an ISO week grouped by day.
+func printWeek(repo domain.Repository, year int, week int) {
+	clearTerminal()
+
+	start, end := utils.WeekRange(year, week)
+	recordings, err := repo.GetRecordingsByDateRange(start, end.AddDate(0, 0, 1))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	Notice("Week ", week, " Year ", year, " (", start.Format("02.01.2006"), " - ", end.Format("02.01.2006"), ")")
+	renderWeekTable(os.Stdout, recordings, start, end, table.StyleColoredBright)
+}
+
+// renderWeekTable writes the recordings grouped by the days from start to end
+// (inclusive) with per-day and total footers. Recordings crossing midnight
+// are split across the days they cover, like in the week matrix.
+func renderWeekTable(w io.Writer, recordings []domain.Recording, start time.Time, end time.Time, style table.Style) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{"Day", "Start", "End", "Duration", "Tag", "Name", "Billable"})
+
+	now := time.Now()
+	var weekTotal time.Duration
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		next := day.AddDate(0, 0, 1)
+		var dayTotal time.Duration
+		label := day.Format("Mon 02.01.")
+		for _, recording := range recordings {
+			recordingEnd := recording.EndTime
+			if recording.IsRunning() {
+				recordingEnd = now
+			}
+			spans := utils.SplitByDay(recording.StartTime, recordingEnd)
+			if len(spans) == 0 {
+				// recordings without duration still show up on their day
+				spans = []utils.Span{{Start: recording.StartTime, End: recording.StartTime}}
+			}
+			for _, span := range spans {
+				if span.Start.Before(day) || !span.Start.Before(next) {
+					continue
+				}
+				endTime := span.End.Format("15:04")
+				if span.End.Equal(next) {
+					endTime = "24:00"
+				}
+				if recording.IsRunning() && span.End.Equal(recordingEnd) {
+					endTime = "running"
+				}
+				duration := span.End.Sub(span.Start)
+				t.AppendRow(table.Row{label, span.Start.Format("15:04"), endTime, utils.FormatDuration(duration), recording.ProjectTag, nameWithLabels(recording), billableString(recording.Billable)})
+				label = ""
+				dayTotal += duration
+			}
+		}
+		if dayTotal > 0 {
+			t.AppendRow(table.Row{"", "", "Day total", utils.FormatDuration(dayTotal)})
+			t.AppendSeparator()
+		}
+		weekTotal += dayTotal
+	}
+	t.AppendFooter(table.Row{"", "", "Total", utils.FormatDuration(weekTotal)})
+	t.SetStyle(style)
+	t.Render()
+}
+
+// printWeekMatrix renders the hours per project and weekday of an ISO week.
+// Recordings crossing midnight are split across the days they cover.
+func printWeekMatrix(repo domain.Repository, year int, week int) {
+	clearTerminal()
+
+	start, end := utils.WeekRange(year, week)
+	end = end.AddDate(0, 0, 1)
+	recordings, err := repo.GetRecordingsByDateRange(start, end)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tags []string
+	hours := map[string]*[7]float64{}
+	var dayTotals [7]float64
+	for _, recording := range recordings {
+		recordingEnd := recording.EndTime
+		if recording.IsRunning() {
+			recordingEnd = time.Now()
+		}
+		for _, span := range utils.SplitByDay(recording.StartTime, recordingEnd) {
+			if span.Start.Before(start) || !span.Start.Before(end) {
+				continue
+			}
+			if _, ok := hours[recording.ProjectTag]; !ok {
+				tags = append(tags, recording.ProjectTag)
+				hours[recording.ProjectTag] = &[7]float64{}
+			}
+			day := int(utils.DayStart(span.Start).Sub(start).Hours()+12) / 24
+			hours[recording.ProjectTag][day] += span.End.Sub(span.Start).Hours()
+			dayTotals[day] += span.End.Sub(span.Start).Hours()
+		}
+	}
+	sort.Strings(tags)
+
+	Notice("Week ", week, " Year ", year, " (", start.Format("02.01.2006"), " - ", end.AddDate(0, 0, -1).Format("02.01.2006"), ")")
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"#", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"})
+	for _, tag := range tags {
+		row := table.Row{tag}
+		for _, h := range hours[tag] {
+			row = append(row, fmt.Sprintf("%.2f", h))
+		}
+		t.AppendRow(row)
+	}
+	t.AppendSeparator()
+	row := table.Row{"#"}
+	var total float64
+	for _, h := range dayTotals {
+		row = append(row, fmt.Sprintf("%.2f", h))
+		total += h
+	}
+	t.AppendRow(row)
+	t.AppendFooter(table.Row{"Total week", fmt.Sprintf("%.2f", total)})
+	t.SetStyle(table.StyleColoredBright)
+	t.Render()
+	Info("Available commands: [next, prev, exit]")
 	InputPrint()
 }
 
-func projectMenu(repo *domain.SQLiteRepository) {
+func weekMatrixMenu(repo domain.Repository, year int, week int) {
+	for {
+		printWeekMatrix(repo, year, week)
+		text, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		// convert CRLF to LF
+		// for Windows
+		text = strings.Replace(text, "\r\n", "", -1)
+		// for Linux
+		text = strings.Replace(text, "\n", "", -1)
+		if text == "next" || text == "n" {
+			year, week = utils.WeekStart(year, week).AddDate(0, 0, 7).ISOWeek()
+		} else if text == "prev" || text == "p" {
+			year, week = utils.WeekStart(year, week).AddDate(0, 0, -7).ISOWeek()
+		} else if text == "exit" || text == "" {
+			break
+		} else {
+			Info("Invalid command")
+			pressEnterToContinue()
+		}
+	}
+}
+
+func projectMenu(repo domain.Repository) {
 	clearTerminal()
-	onlyActive := true
+	active := domain.StatusActive
+	status := &active
 	for {
-		printProjectList(repo, onlyActive)
+		printProjectList(repo, status)
 		text, _ := bufio.NewReader(os.Stdin).ReadString('\n')
 		// convert CRLF to LF
 		// for Windows
@@ -134,28 +471,28 @@ func projectMenu(repo *domain.SQLiteRepository) {
 		if text == "new" {
 			clearTerminal()
 			addProjectForm(repo)
-			printProjectList(repo, onlyActive)
+			printProjectList(repo, status)
 		} else if strings.HasPrefix(text, "edit") {
 			//get the tag
 			args := strings.Split(text, " ")
 			if len(args) < 2 {
 				Info("Please enter a tag")
 				pressEnterToContinue()
-				printProjectList(repo, onlyActive)
+				printProjectList(repo, status)
 			} else {
 				tag := args[1]
 				if tag == "" {
 					Info("Please enter a tag")
 					pressEnterToContinue()
-					printProjectList(repo, onlyActive)
+					printProjectList(repo, status)
 				} else {
 					if _, err := repo.GetProjectByTag(tag); err != nil {
 						Info("Project not found")
 						pressEnterToContinue()
-						printProjectList(repo, onlyActive)
+						printProjectList(repo, status)
 					} else {
 						editProjectForm(repo, tag)
-						printProjectList(repo, onlyActive)
+						printProjectList(repo, status)
 					}
 				}
 			}
@@ -170,30 +507,25 @@ func projectMenu(repo *domain.SQLiteRepository) {
 				if tag == "" {
 					Info("Please enter a tag")
 					pressEnterToContinue()
-					printProjectList(repo, onlyActive)
+					printProjectList(repo, status)
 				} else {
 					if _, err := repo.GetProjectByTag(tag); err != nil {
 						Info("Project not found")
 						pressEnterToContinue()
-						printProjectList(repo, onlyActive)
+						printProjectList(repo, status)
 					} else {
-						err := repo.DeleteProject(tag)
-						if err != nil {
-							log.Fatal(err)
-						} else {
-							clearTerminal()
-							Info("Project deleted successfully!")
-							pressEnterToContinue()
-						}
+						clearTerminal()
+						deleteProjectForm(repo, tag)
+						pressEnterToContinue()
 					}
 				}
 			}
 		} else if strings.HasPrefix(text, "exit") {
 			break
 		} else if text == "all" {
-			onlyActive = false
-		} else if text == "active" {
-			onlyActive = true
+			status = nil
+		} else if parsed, err := domain.ParseProjectStatus(text); err == nil {
+			status = &parsed
 		} else {
 			Info("Invalid command")
 			pressEnterToContinue()
@@ -201,13 +533,18 @@ func projectMenu(repo *domain.SQLiteRepository) {
 	}
 }
 
-func addProjectForm(repo *domain.SQLiteRepository) {
+func addProjectForm(repo domain.Repository) {
 	var (
-		tag         string
-		name        string
-		projectType string
-		status      string
-		confirm     bool
+		tag          string
+		name         string
+		projectType  string
+		status       domain.ProjectStatus
+		rate         string
+		clientID     int64
+		budgetHours  string
+		budgetAmount string
+		budgetPeriod string
+		confirm      bool
 	)
 	form := huh.NewForm(
 		// Gather some final details about the order.
@@ -247,22 +584,41 @@ func addProjectForm(repo *domain.SQLiteRepository) {
 
 			huh.NewSelect[string]().
 				Title("Project type").
-				Options(
-					huh.NewOption("Internal", "internal"),
-					huh.NewOption("Customer", "customer"),
-					huh.NewOption("Development", "development"),
-					huh.NewOption("Open Source", "open source"),
-					huh.NewOption("Other", "other"),
-				).
+				Options(typeOptions(repo)...).
 				Value(&projectType),
 
-			huh.NewSelect[string]().
+			huh.NewSelect[int64]().
+				Title("Client").
+				Options(clientOptions(repo)...).
+				Value(&clientID),
+
+			huh.NewSelect[domain.ProjectStatus]().
 				Title("Status").
+				Options(statusOptions(domain.ProjectStatuses)...).
+				Value(&status),
+			huh.NewInput().
+				Title("Hourly rate ("+viper.GetString("currency")+")").
+				Description("Empty for the client's default rate").
+				Value(&rate).
+				Validate(validateRate),
+			huh.NewInput().
+				Title("Budget in hours (empty for none)").
+				Value(&budgetHours).
+				Validate(validateBudget),
+			huh.NewInput().
+				Title("Budget in "+viper.GetString("currency")+" (empty for none)").
+				Value(&budgetAmount).
+				Validate(validateBudget),
+			huh.NewSelect[string]().
+				Title("Budget period").
 				Options(
-					huh.NewOption("Active", "0"),
-					huh.NewOption("Inactive", "1"),
+					huh.NewOption("Whole project", domain.BudgetTotal),
+					huh.NewOption("Week", domain.BudgetWeek),
+					huh.NewOption("Month", domain.BudgetMonth),
+					huh.NewOption("Quarter", domain.BudgetQuarter),
+					huh.NewOption("Year", domain.BudgetYear),
 				).
-				Value(&status),
+				Value(&budgetPeriod),
 			huh.NewConfirm().
 				Title("Create new project?").
 				Affirmative("Yes!").
@@ -281,13 +637,23 @@ func addProjectForm(repo *domain.SQLiteRepository) {
 				Tag:    tag,
 				Name:   name,
 				Type:   projectType,
-				Status: 0,
+				Status: status,
 			}
-			if status == "1" {
-				project.Status = 1
+			project.HourlyRate, _ = parseRate(rate)
+			project.BudgetHours, _ = parseRate(budgetHours)
+			project.BudgetAmount, _ = parseRate(budgetAmount)
+			project.BudgetPeriod = budgetPeriod
+			project.ClientID = clientID
+			if client, err := projectClient(repo, project); err != nil {
+				log.Fatal(err)
+			} else if client != nil && strings.TrimSpace(rate) == "" {
+				project.HourlyRate = client.HourlyRate
 			}
 			_, err := repo.CreateProject(project)
-			if err != nil {
+			if errors.Is(err, domain.ErrDuplicate) {
+				Info("A project with this tag already exists")
+				return
+			} else if err != nil {
 				log.Fatal(err)
 			}
 			Info("Project created successfully!")
@@ -298,12 +664,17 @@ func addProjectForm(repo *domain.SQLiteRepository) {
 	}
 }
 
-func editProjectForm(repo *domain.SQLiteRepository, tag string) {
+func editProjectForm(repo domain.Repository, tag string) {
 	var (
-		name        string
-		projectType string
-		status      string
-		confirm     bool
+		name         string
+		projectType  string
+		status       domain.ProjectStatus
+		rate         string
+		clientID     int64
+		budgetHours  string
+		budgetAmount string
+		budgetPeriod string
+		confirm      bool
 	)
 
 	project, err := repo.GetProjectByTag(tag)
@@ -312,8 +683,13 @@ func editProjectForm(repo *domain.SQLiteRepository, tag string) {
 	}
 
 	projectType = project.Type
-	status = fmt.Sprintf("%d", project.Status)
+	status = project.Status
 	name = project.Name
+	rate = rateString(project.HourlyRate)
+	clientID = project.ClientID
+	budgetHours = rateString(project.BudgetHours)
+	budgetAmount = rateString(project.BudgetAmount)
+	budgetPeriod = project.BudgetPeriod
 
 	form := huh.NewForm(
 		// Gather some final details about the order.
@@ -335,22 +711,41 @@ func editProjectForm(repo *domain.SQLiteRepository, tag string) {
 
 			huh.NewSelect[string]().
 				Title("Project type").
-				Options(
-					huh.NewOption("Internal", "internal"),
-					huh.NewOption("Customer", "customer"),
-					huh.NewOption("Development", "development"),
-					huh.NewOption("Open Source", "open source"),
-					huh.NewOption("Other", "other"),
-				).
+				Options(typeOptions(repo)...).
 				Value(&projectType),
 
-			huh.NewSelect[string]().
+			huh.NewSelect[int64]().
+				Title("Client").
+				Options(clientOptions(repo)...).
+				Value(&clientID),
+
+			huh.NewSelect[domain.ProjectStatus]().
 				Title("Status").
+				Description("Currently "+project.Status.String()).
+				Options(statusOptions(nextStatuses(project.Status))...).
+				Value(&status),
+			huh.NewInput().
+				Title("Hourly rate ("+viper.GetString("currency")+")").
+				Value(&rate).
+				Validate(validateRate),
+			huh.NewInput().
+				Title("Budget in hours (empty for none)").
+				Value(&budgetHours).
+				Validate(validateBudget),
+			huh.NewInput().
+				Title("Budget in "+viper.GetString("currency")+" (empty for none)").
+				Value(&budgetAmount).
+				Validate(validateBudget),
+			huh.NewSelect[string]().
+				Title("Budget period").
 				Options(
-					huh.NewOption("Active", "0"),
-					huh.NewOption("Inactive", "1"),
+					huh.NewOption("Whole project", domain.BudgetTotal),
+					huh.NewOption("Week", domain.BudgetWeek),
+					huh.NewOption("Month", domain.BudgetMonth),
+					huh.NewOption("Quarter", domain.BudgetQuarter),
+					huh.NewOption("Year", domain.BudgetYear),
 				).
-				Value(&status),
+				Value(&budgetPeriod),
 			huh.NewConfirm().
 				Title("Create new project?").
 				Affirmative("Yes!").
@@ -369,13 +764,18 @@ func editProjectForm(repo *domain.SQLiteRepository, tag string) {
 				Tag:    tag,
 				Name:   name,
 				Type:   projectType,
-				Status: 0,
-			}
-			if status == "1" {
-				project.Status = 1
+				Status: status,
 			}
+			project.HourlyRate, _ = parseRate(rate)
+			project.BudgetHours, _ = parseRate(budgetHours)
+			project.BudgetAmount, _ = parseRate(budgetAmount)
+			project.BudgetPeriod = budgetPeriod
+			project.ClientID = clientID
 			_, err := repo.UpdateProject(tag, project)
-			if err != nil {
+			if errors.Is(err, domain.ErrStatusTransition) {
+				Info(err)
+				return
+			} else if err != nil {
 				log.Fatal(err)
 			}
 			Info("Project updated successfully!")
@@ -386,7 +786,130 @@ func editProjectForm(repo *domain.SQLiteRepository, tag string) {
 	}
 }
 
+// deleteProjectForm deletes a project after confirmation. Projects with
+// recordings can't be deleted directly; their recordings can be moved to
+// another project first, or the project can be archived instead.
+func deleteProjectForm(repo domain.Repository, tag string) {
+	count, err := repo.CountRecordingsByProjectTag(tag)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	action := "delete"
+	if count > 0 {
+		action = "cancel"
+		err := huh.NewSelect[string]().
+			Title(fmt.Sprintf("Project '%s' has %d recordings attached.", tag, count)).
+			Options(
+				huh.NewOption("Cancel", "cancel"),
+				huh.NewOption("Reassign the recordings to another project and delete", "reassign"),
+				huh.NewOption("Archive the project instead", "archive"),
+			).
+			Value(&action).
+			Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	switch action {
+	case "cancel":
+		Info("Project deletion canceled")
+		return
+	case "archive":
+		archiveProject(repo, tag)
+		return
+	case "reassign":
+		var target string
+		var options []huh.Option[string]
+		for _, option := range projectOptions(repo) {
+			if option.Value != tag {
+				options = append(options, option)
+			}
+		}
+		if len(options) == 0 {
+			Info("There is no other project to move the recordings to")
+			return
+		}
+		err := huh.NewSelect[string]().
+			Title("Move the recordings to").
+			Options(options...).
+			Value(&target).
+			Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+		moved, err := repo.ReassignRecordings(tag, target)
+		if err != nil {
+			log.Fatal(err)
+		}
+		Info(fmt.Sprintf("%d recordings moved to '%s'", moved, target))
+		// invoiced recordings stay with their project
+		left, err := repo.CountRecordingsByProjectTag(tag)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if left > 0 {
+			var archive bool
+			err := huh.NewConfirm().
+				Title(fmt.Sprintf("%d invoiced recordings can't be moved, so '%s' can't be deleted. Archive it instead?", left, tag)).
+				Affirmative("Yes!").
+				Negative("No.").
+				Value(&archive).
+				Run()
+			if err != nil {
+				log.Fatal(err)
+			}
+			if archive {
+				archiveProject(repo, tag)
+			} else {
+				Info("Project deletion canceled")
+			}
+			return
+		}
+	case "delete":
+		var confirm bool
+		err := huh.NewConfirm().
+			Title("Delete project '" + tag + "'?").
+			Affirmative("Yes!").
+			Negative("No.").
+			Value(&confirm).
+			Run()
+		if err != nil {
+			log.Fatal(err)
+		}
+		if !confirm {
+			Info("Project deletion canceled")
+			return
+		}
+	}
+
+	if err := repo.DeleteProject(tag); errors.Is(err, domain.ErrProjectInUse) {
+		Info("The project still has recordings, archive it instead")
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	Info("Project deleted successfully!")
+}
+
+func archiveProject(repo domain.Repository, tag string) {
+	project, err := repo.GetProjectByTag(tag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	project.Status = domain.StatusArchived
+	if _, err := repo.UpdateProject(tag, *project); err != nil {
+		log.Fatal(err)
+	}
+	Info("Project archived successfully!")
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:]))
+	}
+
 	clearTerminal()
 	// Create a custom print function for convenience
 	//red := color.New(color.Bold, color.FgWhite, color.BgHiRed).PrintlnFunc()
@@ -409,7 +932,7 @@ func main() {
 
 	for {
 		clearTerminal()
-		printTopBar()
+		printTopBar(TrackingRepositroy)
 		InputPrint()
 		text, _ := bufio.NewReader(os.Stdin).ReadString('\n')
 		// convert CRLF to LF
@@ -421,51 +944,117 @@ func main() {
 		fmt.Println(text)
 		if text == "help" {
 			Info("Available commands:")
-			Info(" week: Show the current week's recordings")
-			Info("  - stop: Stop a recording")
-			Info("  - list: List all recordings")
+			Info(" week [year] [week]: Show the recordings of a week")
+			Info(" week matrix [year] [week]: Show the hours per project and day")
+			Info("  - start (tag) (name): Start a recording, stopping the running one")
+			Info("  - start (tag): Start a recording on one of the project's open tasks")
+			Info("    #label words in the name label the recording, like: start DAG review #meeting")
+			Info("  - stop: Stop the running recording")
+			Info("  - project: Manage projects")
+			Info("  - clients: Manage clients")
+			Info("  - types: Manage project types")
+			Info("  - tasks: Manage tasks")
+			Info("  - recordings: Manage recordings")
 			Info("  - exit: Exit the application")
-			TrackingRepositroy.CreateRecording(domain.Recording{ProjectTag: "test", Name: "test", Billable: true, Status: 0})
+			Info("The command line commands can be used here as well:")
+			printCommandUsage(os.Stdout)
 			pressEnterToContinue()
-		} else if text == "week" || text == "w" {
-			clearTerminal()
-			t := table.NewWriter()
-			t.SetOutputMirror(os.Stdout)
-			t.AppendHeader(table.Row{"#", "First Name", "Last Name", "Salary"})
-			t.AppendRows([]table.Row{
-				{1, "Arya", "Stark", 3000},
-				{20, "Jon", "Snow", 2000, "You know nothing, Jon Snow!"},
-			})
-			t.AppendSeparator()
-			t.AppendRow([]interface{}{300, "Tyrion", "Lannister", 5000})
-			t.AppendFooter(table.Row{"", "", "Total", 10000})
-			t.SetStyle(table.StyleColoredBright)
-			t.Render()
-
+		} else if text == "start" || strings.HasPrefix(text, "start ") {
+			args := strings.SplitN(text, " ", 3)
+			var taskID int64
+			var labels []string
+			if len(args) == 3 {
+				name, nameLabels, err := parseLabels(args[2])
+				if err != nil {
+					Info(err)
+					pressEnterToContinue()
+					continue
+				}
+				args[2], labels = name, nameLabels
+				if name == "" {
+					args = args[:2]
+				}
+			}
+			if len(args) == 2 && args[1] != "" {
+				// without a name, the recording is booked on a task
+				if task := selectTaskForm(TrackingRepositroy, args[1]); task != nil {
+					taskID = task.ID
+					args = append(args, task.Name)
+				}
+			}
+			if len(args) < 3 || args[1] == "" || strings.TrimSpace(args[2]) == "" {
+				Info("Usage: start (tag) (name), or start (tag) to pick a task")
+			} else {
+				started, stopped, err := startRecording(TrackingRepositroy, args[1], strings.TrimSpace(args[2]), taskID, labels)
+				if errors.Is(err, domain.ErrNotExists) {
+					Info("Project not found")
+				} else if errors.Is(err, domain.ErrProjectClosed) {
+					Info("The project is completed or archived, set it active to start recordings")
+				} else if errors.Is(err, domain.ErrRunning) {
+					Info("Another recording is running, stop it first")
+				} else if err != nil {
+					log.Fatal(err)
+				} else {
+					if stopped != nil {
+						Info("Stopped ", stopped.ProjectTag, " - ", stopped.Name, " (", utils.FormatDuration(stopped.Duration()), ")")
+					}
+					Notice("Started ", started.ProjectTag, " - ", started.Name)
+					if warning, err := budgetWarning(TrackingRepositroy, started.ProjectTag); err != nil {
+						log.Fatal(err)
+					} else if warning != "" {
+						Info(warning)
+					}
+				}
+			}
 			pressEnterToContinue()
-		} else if text == "week matrix" || text == "w m" {
-			clearTerminal()
-			t := table.NewWriter()
-			t.SetOutputMirror(os.Stdout)
-			t.AppendHeader(table.Row{"#", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"})
-			t.AppendRows([]table.Row{
-				{"DAG", 4.25, 2.00, 1.00, 3.00, 5.00, 0.00, 0.00},
-				{"INT", 10.00, 4.00, 7.00, 6.00, 8.00, 0.00, 0.00},
-			})
-			t.AppendSeparator()
-			t.AppendRow([]interface{}{"#", 16.25, 6.00, 8.00, 9.00, 13.00, 0.00, 0.00})
-			t.AppendFooter(table.Row{"Total week", 52.25})
-			t.SetStyle(table.StyleColoredBright)
-			t.Render()
-
+		} else if text == "stop" {
+			stopped, err := stopRecording(TrackingRepositroy)
+			if errors.Is(err, domain.ErrNotExists) {
+				Info("No recording is running")
+			} else if err != nil {
+				log.Fatal(err)
+			} else {
+				Notice("Stopped ", stopped.ProjectTag, " - ", stopped.Name, " (", utils.FormatDuration(stopped.Duration()), ")")
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "week matrix") || text == "w m" || strings.HasPrefix(text, "w m ") {
+			args := strings.Fields(text)[2:]
+			year, week, err := parseWeekArgs(args)
+			if err != nil {
+				Info(err)
+				pressEnterToContinue()
+			} else {
+				weekMatrixMenu(TrackingRepositroy, year, week)
+			}
+		} else if text == "week" || text == "w" || strings.HasPrefix(text, "week ") || strings.HasPrefix(text, "w ") {
+			year, week, err := parseWeekArgs(strings.Fields(text)[1:])
+			if err != nil {
+				Info(err)
+			} else {
+				printWeek(TrackingRepositroy, year, week)
+			}
 			pressEnterToContinue()
 		} else if text == "project list" || text == "projects" || text == "project" || text == "p" {
 			projectMenu(TrackingRepositroy)
+		} else if text == "tasks" || text == "task" || text == "t" {
+			taskMenu(TrackingRepositroy)
+		} else if text == "types" || text == "type" {
+			projectTypeMenu(TrackingRepositroy)
+		} else if text == "clients" || text == "client" || text == "c" {
+			clientMenu(TrackingRepositroy)
+		} else if text == "recordings" || text == "recording" || text == "r" {
+			recordingMenu(TrackingRepositroy)
 		} else if text == "project new" {
 			clearTerminal()
 			addProjectForm(TrackingRepositroy)
 		} else if text == "exit" {
 			break
+		} else if text != "" {
+			clearTerminal()
+			if err := runCommand(TrackingRepositroy, splitArgs(text)); err != nil {
+				Info(err)
+			}
+			pressEnterToContinue()
 		}
 	}
 
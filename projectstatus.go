@@ -0,0 +1,53 @@
+package main
+
+import (
+	"downardo.at/timetracking/internal/domain"
+	"github.com/charmbracelet/huh"
+)
+
+// projectsWithStatus keeps the projects in the given state.
+func projectsWithStatus(projects []domain.Project, status domain.ProjectStatus) []domain.Project {
+	var kept []domain.Project
+	for _, project := range projects {
+		if project.Status == status {
+			kept = append(kept, project)
+		}
+	}
+	return kept
+}
+
+// pickableProjects returns the projects offered for selection. Archived
+// projects are left out, their recordings still show up in reports.
+func pickableProjects(repo domain.Repository) ([]domain.Project, error) {
+	projects, err := repo.AllProjects()
+	if err != nil {
+		return nil, err
+	}
+	var pickable []domain.Project
+	for _, project := range projects {
+		if project.Status != domain.StatusArchived {
+			pickable = append(pickable, project)
+		}
+	}
+	return pickable, nil
+}
+
+// nextStatuses lists the states a project in the given state may change to,
+// starting with its current state.
+func nextStatuses(current domain.ProjectStatus) []domain.ProjectStatus {
+	statuses := []domain.ProjectStatus{current}
+	for _, status := range domain.ProjectStatuses {
+		if status != current && current.CanBecome(status) {
+			statuses = append(statuses, status)
+		}
+	}
+	return statuses
+}
+
+func statusOptions(statuses []domain.ProjectStatus) []huh.Option[domain.ProjectStatus] {
+	var options []huh.Option[domain.ProjectStatus]
+	for _, status := range statuses {
+		options = append(options, huh.NewOption(status.String(), status))
+	}
+	return options
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+
+	"downardo.at/timetracking/internal/domain"
+	"github.com/charmbracelet/huh"
+	"github.com/fatih/color"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+var typeColors = map[string]color.Attribute{
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+}
+
+// colorize prints text in one of domain.ProjectTypeColors. Colors are left
+// out when the output is no terminal.
+func colorize(name string, text string) string {
+	attribute, ok := typeColors[name]
+	if !ok {
+		return text
+	}
+	return color.New(attribute).Sprint(text)
+}
+
+// projectTypes maps the type names to the types for project lists.
+func projectTypes(repo domain.Repository) (map[string]domain.ProjectType, error) {
+	all, err := repo.AllProjectTypes()
+	if err != nil {
+		return nil, err
+	}
+	types := map[string]domain.ProjectType{}
+	for _, projectType := range all {
+		types[projectType.Name] = projectType
+	}
+	return types, nil
+}
+
+// typeString is the type of a project in its color.
+func typeString(types map[string]domain.ProjectType, name string) string {
+	return colorize(types[name].Color, name)
+}
+
+// typeOptions lists the project types for selection.
+func typeOptions(repo domain.Repository) []huh.Option[string] {
+	types, err := repo.AllProjectTypes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var options []huh.Option[string]
+	for _, projectType := range types {
+		options = append(options, huh.NewOption(projectType.Name, projectType.Name))
+	}
+	return options
+}
+
+// billableDefault reports whether new recordings of projects of the type are
+// billable. Unknown types are not.
+func billableDefault(repo domain.Repository, name string) (bool, error) {
+	projectType, err := repo.GetProjectType(name)
+	if errors.Is(err, domain.ErrNotExists) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return projectType.BillableDefault, nil
+}
+
+// validateTypeName checks that a name is set, fits the project table and is
+// not taken.
+func validateTypeName(repo domain.Repository, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("please enter a name")
+	}
+	if len(name) > 20 {
+		return errors.New("name is too long")
+	}
+	if _, err := repo.GetProjectType(name); err == nil {
+		return errors.New("project type already exists")
+	}
+	return nil
+}
+
+func validateTypeColor(name string) error {
+	if !slices.Contains(domain.ProjectTypeColors, name) {
+		return fmt.Errorf("unknown color %q, use red, green, yellow, blue, magenta or cyan", name)
+	}
+	return nil
+}
+
+func printProjectTypeList(repo domain.Repository) {
+	clearTerminal()
+
+	types, err := repo.AllProjectTypes()
+	if err != nil {
+		log.Fatal(err)
+	}
+	Notice("Project Type List")
+	renderProjectTypes(types, table.StyleDouble)
+	Info("Available commands: [new, edit (name), delete (name), exit]")
+	InputPrint()
+}
+
+func renderProjectTypes(types []domain.ProjectType, style table.Style) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Name", "Billable by default", "Color"})
+	for _, projectType := range types {
+		t.AppendRow(table.Row{colorize(projectType.Color, projectType.Name), billableString(projectType.BillableDefault), projectType.Color})
+	}
+	t.SetStyle(style)
+	t.Render()
+}
+
+// parseTypeName reads the type name argument of a menu command. Names may
+// contain spaces.
+func parseTypeName(repo domain.Repository, text string, command string) (*domain.ProjectType, error) {
+	name := strings.TrimSpace(strings.TrimPrefix(text, command))
+	if name == "" {
+		return nil, errors.New("please enter a name")
+	}
+	projectType, err := repo.GetProjectType(name)
+	if err != nil {
+		return nil, errors.New("project type not found")
+	}
+	return projectType, nil
+}
+
+func projectTypeMenu(repo domain.Repository) {
+	clearTerminal()
+	for {
+		printProjectTypeList(repo)
+		text, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		// convert CRLF to LF
+		// for Windows
+		text = strings.Replace(text, "\r\n", "", -1)
+		// for Linux
+		text = strings.Replace(text, "\n", "", -1)
+		if text == "new" {
+			clearTerminal()
+			addProjectTypeForm(repo)
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "edit") {
+			projectType, err := parseTypeName(repo, text, "edit")
+			if err != nil {
+				Info(err)
+			} else {
+				clearTerminal()
+				editProjectTypeForm(repo, projectType)
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "delete") {
+			projectType, err := parseTypeName(repo, text, "delete")
+			if err != nil {
+				Info(err)
+			} else {
+				clearTerminal()
+				deleteProjectTypeForm(repo, projectType)
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "exit") {
+			break
+		} else {
+			Info("Invalid command")
+			pressEnterToContinue()
+		}
+	}
+}
+
+// projectTypeDefaults asks for the defaults of a project type. It returns
+// false if the user did not confirm.
+func projectTypeDefaults(projectType *domain.ProjectType, fields ...huh.Field) bool {
+	var options []huh.Option[string]
+	for _, name := range domain.ProjectTypeColors {
+		label := name
+		if name == "" {
+			label = "default"
+		}
+		options = append(options, huh.NewOption(label, name))
+	}
+	confirm := false
+	fields = append(fields,
+		huh.NewConfirm().
+			Title("Billable by default?").
+			Description("New recordings of the type's projects are billable, and the projects can be invoiced").
+			Value(&projectType.BillableDefault),
+		huh.NewSelect[string]().
+			Title("Color").
+			Options(options...).
+			Value(&projectType.Color),
+		huh.NewConfirm().
+			Title("Save project type?").
+			Affirmative("Yes!").
+			Negative("No.").
+			Value(&confirm),
+	)
+	if err := huh.NewForm(huh.NewGroup(fields...)).Run(); err != nil {
+		log.Fatal(err)
+	}
+	return confirm
+}
+
+func addProjectTypeForm(repo domain.Repository) {
+	projectType := domain.ProjectType{}
+	name := huh.NewInput().
+		Title("Name").
+		CharLimit(20).
+		Value(&projectType.Name).
+		Validate(func(str string) error {
+			return validateTypeName(repo, str)
+		})
+	if !projectTypeDefaults(&projectType, huh.NewNote().Title("New project type"), name) {
+		Info("Project type creation canceled")
+		return
+	}
+	if _, err := repo.CreateProjectType(projectType); err != nil {
+		log.Fatal(err)
+	}
+	Info("Project type created successfully!")
+}
+
+func editProjectTypeForm(repo domain.Repository, projectType *domain.ProjectType) {
+	if !projectTypeDefaults(projectType, huh.NewNote().Title("Edit project type "+projectType.Name)) {
+		Info("Project type update canceled")
+		return
+	}
+	if _, err := repo.UpdateProjectType(projectType.Name, *projectType); err != nil {
+		log.Fatal(err)
+	}
+	Info("Project type updated successfully!")
+}
+
+func deleteProjectTypeForm(repo domain.Repository, projectType *domain.ProjectType) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title("Delete project type '" + projectType.Name + "'?").
+		Affirmative("Yes!").
+		Negative("No.").
+		Value(&confirm).
+		Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Project type deletion canceled")
+		return
+	}
+	if err := repo.DeleteProjectType(projectType.Name); errors.Is(err, domain.ErrTypeInUse) {
+		Info("There are still projects of this type, change their type first")
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	Info("Project type deleted successfully!")
+}
+
+func cmdType(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing type command", errUsage)
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("type add", flag.ContinueOnError)
+		name := fs.String("name", "", "type name")
+		billable := fs.Bool("billable", false, "make new recordings of the type's projects billable")
+		typeColor := fs.String("color", "", "color in project lists")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 || *name == "" {
+			return fmt.Errorf("%w: --name is required", errUsage)
+		}
+		if err := validateTypeName(repo, *name); err != nil {
+			return err
+		}
+		if err := validateTypeColor(*typeColor); err != nil {
+			return fmt.Errorf("%w: %v", errUsage, err)
+		}
+		if _, err := repo.CreateProjectType(domain.ProjectType{Name: *name, BillableDefault: *billable, Color: *typeColor}); err != nil {
+			return err
+		}
+		fmt.Printf("Created project type %s\n", *name)
+	case "list":
+		if len(args) > 1 {
+			return fmt.Errorf("%w: unexpected argument %q", errUsage, args[1])
+		}
+		types, err := repo.AllProjectTypes()
+		if err != nil {
+			return err
+		}
+		renderProjectTypes(types, table.StyleLight)
+	case "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: type name is required", errUsage)
+		}
+		if err := repo.DeleteProjectType(args[1]); errors.Is(err, domain.ErrTypeInUse) {
+			return fmt.Errorf("there are still projects of type %q", args[1])
+		} else if errors.Is(err, domain.ErrDeleteFailed) {
+			return fmt.Errorf("project type %q not found", args[1])
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted project type %s\n", args[1])
+	default:
+		return fmt.Errorf("%w: unknown type command %q", errUsage, args[0])
+	}
+	return nil
+}
@@ -0,0 +1,491 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/utils"
+	"github.com/charmbracelet/huh"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+func billableString(billable bool) string {
+	if billable {
+		return "yes"
+	}
+	return "no"
+}
+
+func filterString(filter domain.RecordingFilter) string {
+	var parts []string
+	if filter.ProjectTag != "" {
+		parts = append(parts, "project "+filter.ProjectTag)
+	}
+	if !filter.From.IsZero() {
+		parts = append(parts, "from "+filter.From.Format(utils.DateLayout))
+	}
+	if !filter.To.IsZero() {
+		parts = append(parts, "to "+filter.To.AddDate(0, 0, -1).Format(utils.DateLayout))
+	}
+	if filter.Billable != nil {
+		parts = append(parts, "billable "+billableString(*filter.Billable))
+	}
+	if filter.Label != "" {
+		parts = append(parts, "label #"+filter.Label)
+	}
+	if len(parts) == 0 {
+		return "none"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func printRecordingList(repo domain.Repository, filter domain.RecordingFilter) {
+	clearTerminal()
+
+	recordings, err := repo.FindRecordings(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	Notice("Recording List - Filter: " + filterString(filter))
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"ID", "Tag", "Start", "End", "Duration", "Name", "Labels", "Billable", "Note"})
+
+	var total time.Duration
+	for _, recording := range recordings {
+		endTime := "running"
+		if !recording.IsRunning() {
+			endTime = recording.EndTime.Format(utils.DateTimeLayout)
+		}
+		billable := billableString(recording.Billable)
+		if recording.IsInvoiced() {
+			billable += fmt.Sprintf(" (invoice %d)", recording.InvoiceNumber)
+		}
+		t.AppendRow([]interface{}{recording.ID, recording.ProjectTag, recording.StartTime.Format(utils.DateTimeLayout), endTime, utils.FormatDuration(recording.Duration()), recording.Name, labelsString(recording.Labels), billable, recording.Note})
+		total += recording.Duration()
+	}
+	t.AppendFooter(table.Row{"", "", "", "Total", utils.FormatDuration(total)})
+	t.SetStyle(table.StyleDouble)
+	t.Render()
+	Info("Available commands: [new, edit (id), delete (id), filter, clear, exit] [id]")
+	InputPrint()
+}
+
+// parseRecordingID reads the recording ID argument of a menu command.
+func parseRecordingID(repo domain.Repository, text string) (*domain.Recording, error) {
+	args := strings.Split(text, " ")
+	if len(args) < 2 || args[1] == "" {
+		return nil, errors.New("please enter an id")
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("id has to be a number")
+	}
+	recording, err := repo.GetRecordingByID(id)
+	if err != nil {
+		return nil, errors.New("recording not found")
+	}
+	return recording, nil
+}
+
+func recordingMenu(repo domain.Repository) {
+	clearTerminal()
+	year, week := time.Now().ISOWeek()
+	filter := domain.RecordingFilter{From: utils.WeekStart(year, week)}
+	for {
+		printRecordingList(repo, filter)
+		text, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		// convert CRLF to LF
+		// for Windows
+		text = strings.Replace(text, "\r\n", "", -1)
+		// for Linux
+		text = strings.Replace(text, "\n", "", -1)
+		if text == "new" {
+			clearTerminal()
+			addRecordingForm(repo)
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "edit") {
+			recording, err := parseRecordingID(repo, text)
+			if err != nil {
+				Info(err)
+			} else if recording.IsInvoiced() {
+				Info(fmt.Sprintf("Recording is on invoice %d and can't be changed", recording.InvoiceNumber))
+			} else {
+				clearTerminal()
+				editRecordingForm(repo, recording)
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "delete") {
+			recording, err := parseRecordingID(repo, text)
+			if err != nil {
+				Info(err)
+			} else if recording.IsInvoiced() {
+				Info(fmt.Sprintf("Recording is on invoice %d and can't be deleted", recording.InvoiceNumber))
+			} else {
+				clearTerminal()
+				deleteRecordingForm(repo, recording)
+			}
+			pressEnterToContinue()
+		} else if text == "filter" {
+			clearTerminal()
+			filter = filterRecordingsForm(repo, filter)
+		} else if text == "clear" {
+			filter = domain.RecordingFilter{}
+		} else if strings.HasPrefix(text, "exit") {
+			break
+		} else {
+			Info("Invalid command")
+			pressEnterToContinue()
+		}
+	}
+}
+
+func projectOptions(repo domain.Repository) []huh.Option[string] {
+	projects, err := pickableProjects(repo)
+	if err != nil {
+		log.Fatal(err)
+	}
+	var options []huh.Option[string]
+	for _, project := range projects {
+		options = append(options, huh.NewOption(project.Tag+" - "+project.Name, project.Tag))
+	}
+	return options
+}
+
+func validateDateTime(str string) error {
+	if _, err := utils.ParseDateTime(str); err != nil {
+		return errors.New("please enter a time like " + utils.DateTimeLayout)
+	}
+	return nil
+}
+
+func validateDate(str string) error {
+	if str == "" {
+		return nil
+	}
+	if _, err := utils.ParseDate(str); err != nil {
+		return errors.New("please enter a date like " + utils.DateLayout)
+	}
+	return nil
+}
+
+func filterRecordingsForm(repo domain.Repository, filter domain.RecordingFilter) domain.RecordingFilter {
+	var (
+		tag      = filter.ProjectTag
+		from     string
+		to       string
+		billable = "any"
+		label    = filter.Label
+	)
+	if !filter.From.IsZero() {
+		from = filter.From.Format(utils.DateLayout)
+	}
+	if !filter.To.IsZero() {
+		to = filter.To.AddDate(0, 0, -1).Format(utils.DateLayout)
+	}
+	if filter.Billable != nil {
+		billable = billableString(*filter.Billable)
+	}
+
+	projects, err := repo.AllProjects()
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := []huh.Option[string]{huh.NewOption("All projects", "")}
+	for _, project := range projects {
+		options = append(options, huh.NewOption(project.Tag+" - "+project.Name, project.Tag))
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Project").
+				Options(options...).
+				Value(&tag),
+			huh.NewInput().
+				Title("From ("+utils.DateLayout+", empty for no limit)").
+				Value(&from).
+				Validate(validateDate),
+			huh.NewInput().
+				Title("To ("+utils.DateLayout+", empty for no limit)").
+				Value(&to).
+				Validate(validateDate),
+			huh.NewSelect[string]().
+				Title("Billable").
+				Options(
+					huh.NewOption("Any", "any"),
+					huh.NewOption("Billable", "yes"),
+					huh.NewOption("Not billable", "no"),
+				).
+				Value(&billable),
+			huh.NewSelect[string]().
+				Title("Label").
+				Options(labelOptions(repo)...).
+				Value(&label),
+		),
+	)
+
+	if err := form.Run(); err != nil {
+		log.Fatal(err)
+	}
+
+	filter = domain.RecordingFilter{ProjectTag: tag, Label: label}
+	if from != "" {
+		filter.From, _ = utils.ParseDate(from)
+	}
+	if to != "" {
+		toDate, _ := utils.ParseDate(to)
+		filter.To = toDate.AddDate(0, 0, 1)
+	}
+	if billable != "any" {
+		b := billable == "yes"
+		filter.Billable = &b
+	}
+	return filter
+}
+
+func addRecordingForm(repo domain.Repository) {
+	var (
+		tag      string
+		name     string
+		start    = time.Now().Format(utils.DateTimeLayout)
+		end      = time.Now().Format(utils.DateTimeLayout)
+		note     string
+		billable bool
+		rate     string
+		taskID   int64
+		confirm  bool
+	)
+	options := projectOptions(repo)
+	if len(options) == 0 {
+		Info("There are no projects to record on, add one first")
+		return
+	}
+	// the task options depend on the project, so it is asked for first
+	err := huh.NewSelect[string]().
+		Title("Project").
+		Options(options...).
+		Value(&tag).
+		Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	project, err := repo.GetProjectByTag(tag)
+	if errors.Is(err, domain.ErrNotExists) {
+		Info("Project not found")
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	if billable, err = billableDefault(repo, project.Type); err != nil {
+		log.Fatal(err)
+	}
+	if task := selectTaskForm(repo, tag); task != nil {
+		taskID = task.ID
+		name = task.Name
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title("New recording ("+tag+")"),
+			huh.NewInput().
+				Title("Name").
+				Description("Add labels with #label, like: review #meeting").
+				Value(&name).
+				Validate(validateNameWithLabels),
+			huh.NewInput().
+				Title("Start ("+utils.DateTimeLayout+")").
+				Value(&start).
+				Validate(validateDateTime),
+			huh.NewInput().
+				Title("End ("+utils.DateTimeLayout+")").
+				Value(&end).
+				Validate(func(str string) error {
+					if err := validateDateTime(str); err != nil {
+						return err
+					}
+					startTime, _ := utils.ParseDateTime(start)
+					endTime, _ := utils.ParseDateTime(str)
+					if !endTime.After(startTime) {
+						return errors.New("end has to be after start")
+					}
+					return nil
+				}),
+			huh.NewText().
+				Title("Note").
+				Value(&note),
+			huh.NewConfirm().
+				Title("Billable?").
+				Value(&billable),
+			huh.NewInput().
+				Title("Hourly rate (empty for the project's rate)").
+				Value(&rate).
+				Validate(validateRate),
+			huh.NewConfirm().
+				Title("Create new recording?").
+				Affirmative("Yes!").
+				Negative("No.").
+				Value(&confirm),
+		),
+	)
+
+	err = form.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Recording creation canceled")
+		return
+	}
+
+	startTime, _ := utils.ParseDateTime(start)
+	endTime, _ := utils.ParseDateTime(end)
+	hourlyRate, _ := parseRate(rate)
+	name, labels, _ := parseLabels(name)
+	_, err = repo.CreateRecording(domain.Recording{
+		ProjectTag: tag,
+		StartTime:  startTime,
+		EndTime:    endTime,
+		Name:       name,
+		Labels:     labels,
+		Billable:   billable,
+		Note:       note,
+		Status:     0,
+		HourlyRate: hourlyRate,
+		TaskID:     taskID,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	Info("Recording created successfully!")
+}
+
+func editRecordingForm(repo domain.Repository, recording *domain.Recording) {
+	var (
+		name     = nameWithLabels(*recording)
+		start    = recording.StartTime.Format(utils.DateTimeLayout)
+		end      string
+		note     = recording.Note
+		billable = recording.Billable
+		rate     = rateString(recording.HourlyRate)
+		taskID   = recording.TaskID
+		confirm  bool
+	)
+	if !recording.IsRunning() {
+		end = recording.EndTime.Format(utils.DateTimeLayout)
+	}
+
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title(fmt.Sprintf("Edit recording #%d (%s)", recording.ID, recording.ProjectTag)),
+			huh.NewInput().
+				Title("Name").
+				Description("Add labels with #label, like: review #meeting").
+				Value(&name).
+				Validate(validateNameWithLabels),
+			huh.NewSelect[int64]().
+				Title("Task").
+				Options(taskOptions(repo, recording.ProjectTag, recording.TaskID)...).
+				Value(&taskID),
+			huh.NewInput().
+				Title("Start ("+utils.DateTimeLayout+")").
+				Value(&start).
+				Validate(validateDateTime),
+			huh.NewInput().
+				Title("End ("+utils.DateTimeLayout+", empty while running)").
+				Value(&end).
+				Validate(func(str string) error {
+					if str == "" && recording.IsRunning() {
+						return nil
+					}
+					if err := validateDateTime(str); err != nil {
+						return err
+					}
+					startTime, _ := utils.ParseDateTime(start)
+					endTime, _ := utils.ParseDateTime(str)
+					if !endTime.After(startTime) {
+						return errors.New("end has to be after start")
+					}
+					return nil
+				}),
+			huh.NewText().
+				Title("Note").
+				Value(&note),
+			huh.NewConfirm().
+				Title("Billable?").
+				Value(&billable),
+			huh.NewInput().
+				Title("Hourly rate (empty for the project's rate)").
+				Value(&rate).
+				Validate(validateRate),
+			huh.NewConfirm().
+				Title("Save recording?").
+				Affirmative("Yes!").
+				Negative("No.").
+				Value(&confirm),
+		),
+	)
+
+	err := form.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Recording update canceled")
+		return
+	}
+
+	updated := *recording
+	updated.Name, updated.Labels, _ = parseLabels(name)
+	updated.Note = note
+	updated.Billable = billable
+	updated.HourlyRate, _ = parseRate(rate)
+	updated.TaskID = taskID
+	startTime, _ := utils.ParseDateTime(start)
+	// keep the seconds of unchanged times
+	if startTime.Format(utils.DateTimeLayout) != recording.StartTime.Format(utils.DateTimeLayout) {
+		updated.StartTime = startTime
+	}
+	if end == "" {
+		updated.EndTime = time.Time{}
+	} else if end != recording.EndTime.Format(utils.DateTimeLayout) || recording.IsRunning() {
+		updated.EndTime, _ = utils.ParseDateTime(end)
+	}
+	if _, err := repo.UpdateRecording(recording.ID, updated); errors.Is(err, domain.ErrRunning) {
+		Info("Another recording is running, stop it first")
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	Info("Recording updated successfully!")
+}
+
+func deleteRecordingForm(repo domain.Repository, recording *domain.Recording) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Delete recording #%d (%s - %s, %s)?", recording.ID, recording.ProjectTag, recording.Name, recording.StartTime.Format(utils.DateTimeLayout))).
+		Affirmative("Yes!").
+		Negative("No.").
+		Value(&confirm).
+		Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Recording deletion canceled")
+		return
+	}
+	if err := repo.DeleteRecording(recording.ID); err != nil {
+		log.Fatal(err)
+	}
+	Info("Recording deleted successfully!")
+}
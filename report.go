@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"downardo.at/timetracking/internal/report"
+	"downardo.at/timetracking/internal/utils"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/viper"
+)
+
+// formatAmounts formats the amounts of a total, one per currency. A total
+// without amounts shows 0 in the configured currency.
+func formatAmounts(total report.Total) string {
+	if len(total.Amounts) == 0 {
+		return fmt.Sprintf("%.2f %s", 0.0, viper.GetString("currency"))
+	}
+	var amounts []string
+	for _, currency := range total.Currencies() {
+		amounts = append(amounts, fmt.Sprintf("%.2f %s", total.Amounts[currency], currency))
+	}
+	return strings.Join(amounts, ", ")
+}
+
+// rateString formats an hourly rate for lists and forms, empty if unset.
+func rateString(rate float64) string {
+	if rate == 0 {
+		return ""
+	}
+	return strconv.FormatFloat(rate, 'f', -1, 64)
+}
+
+// parseRate parses an hourly rate, accepting a decimal comma. An empty
+// string is no rate.
+func parseRate(str string) (float64, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, nil
+	}
+	return strconv.ParseFloat(strings.Replace(str, ",", ".", 1), 64)
+}
+
+func validateRate(str string) error {
+	if rate, err := parseRate(str); err != nil || rate < 0 {
+		return errors.New("please enter a rate like 80 or 72.50")
+	}
+	return nil
+}
+
+// renderSummary renders the totals of a summary as tables per client, per
+// project, per project type, per label and per day. Days without recordings
+// are left out.
+func renderSummary(w io.Writer, summary *report.Summary, style table.Style) {
+	renderTotals(w, "Client", summary.ByClient, summary.Total, style)
+	renderTotals(w, "Project", summary.ByProject, summary.Total, style)
+	renderTotals(w, "Type", summary.ByType, summary.Total, style)
+	renderTotals(w, "Label", summary.ByLabel, summary.Total, style)
+
+	var days []report.Total
+	for _, day := range summary.ByDay {
+		if day.Duration > 0 {
+			days = append(days, day)
+		}
+	}
+	renderTotals(w, "Day", days, summary.Total, style)
+}
+
+func renderTotals(w io.Writer, title string, totals []report.Total, sum report.Total, style table.Style) {
+	t := table.NewWriter()
+	t.SetOutputMirror(w)
+	t.AppendHeader(table.Row{title, "Hours", "Billable", "Not billable", "Amount"})
+	for _, total := range totals {
+		key := total.Key
+		if title == "Day" {
+			day, _ := utils.ParseDate(key)
+			key = day.Format("Mon 02.01.2006")
+		}
+		t.AppendRow(table.Row{key, utils.FormatDuration(total.Duration), utils.FormatDuration(total.Billable), utils.FormatDuration(total.NonBillable()), formatAmounts(total)})
+	}
+	t.AppendFooter(table.Row{"Total", utils.FormatDuration(sum.Duration), utils.FormatDuration(sum.Billable), utils.FormatDuration(sum.NonBillable()), formatAmounts(sum)})
+	t.SetStyle(style)
+	t.Render()
+}
+
+// summaryTotal is the JSON representation of a report total. Amounts are
+// keyed by currency.
+type summaryTotal struct {
+	Key              string             `json:"key,omitempty"`
+	Hours            float64            `json:"hours"`
+	BillableHours    float64            `json:"billableHours"`
+	NonBillableHours float64            `json:"nonBillableHours"`
+	Amounts          map[string]float64 `json:"amounts"`
+}
+
+func newSummaryTotals(totals []report.Total) []summaryTotal {
+	result := []summaryTotal{}
+	for _, total := range totals {
+		result = append(result, newSummaryTotal(total))
+	}
+	return result
+}
+
+func newSummaryTotal(total report.Total) summaryTotal {
+	amounts := map[string]float64{}
+	for currency, amount := range total.Amounts {
+		amounts[currency] = math.Round(amount*100) / 100
+	}
+	return summaryTotal{
+		Key:              total.Key,
+		Hours:            total.Duration.Hours(),
+		BillableHours:    total.Billable.Hours(),
+		NonBillableHours: total.NonBillable().Hours(),
+		Amounts:          amounts,
+	}
+}
+
+// writeSummaryJSON writes a summary; "to" is the last day of the range.
+func writeSummaryJSON(w io.Writer, summary *report.Summary) error {
+	data := struct {
+		From     string         `json:"from"`
+		To       string         `json:"to"`
+		Total    summaryTotal   `json:"total"`
+		Clients  []summaryTotal `json:"clients"`
+		Projects []summaryTotal `json:"projects"`
+		Types    []summaryTotal `json:"types"`
+		Labels   []summaryTotal `json:"labels"`
+		Days     []summaryTotal `json:"days"`
+	}{
+		From:     summary.From.Format(utils.DateLayout),
+		To:       summary.To.AddDate(0, 0, -1).Format(utils.DateLayout),
+		Total:    newSummaryTotal(summary.Total),
+		Clients:  newSummaryTotals(summary.ByClient),
+		Projects: newSummaryTotals(summary.ByProject),
+		Types:    newSummaryTotals(summary.ByType),
+		Labels:   newSummaryTotals(summary.ByLabel),
+		Days:     newSummaryTotals(summary.ByDay),
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// writeSummaryCSV writes one line per total, the group column telling
+// which grouping it belongs to. There is an amount column per currency, like
+// amount_EUR.
+func writeSummaryCSV(w io.Writer, summary *report.Summary) error {
+	currencies := summary.Total.Currencies()
+	if len(currencies) == 0 {
+		currencies = []string{viper.GetString("currency")}
+	}
+	header := []string{"group", "key", "hours", "billable_hours", "non_billable_hours"}
+	for _, currency := range currencies {
+		header = append(header, "amount_"+currency)
+	}
+	writer := csv.NewWriter(w)
+	writer.Write(header)
+	write := func(group string, totals ...report.Total) {
+		for _, total := range totals {
+			record := []string{group, total.Key, hoursString(total.Duration), hoursString(total.Billable), hoursString(total.NonBillable())}
+			for _, currency := range currencies {
+				record = append(record, strconv.FormatFloat(total.Amounts[currency], 'f', 2, 64))
+			}
+			writer.Write(record)
+		}
+	}
+	write("total", summary.Total)
+	write("client", summary.ByClient...)
+	write("project", summary.ByProject...)
+	write("type", summary.ByType...)
+	write("label", summary.ByLabel...)
+	write("day", summary.ByDay...)
+	writer.Flush()
+	return writer.Error()
+}
+
+func hoursString(d time.Duration) string {
+	return strconv.FormatFloat(d.Hours(), 'f', 2, 64)
+}
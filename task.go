@@ -0,0 +1,543 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"downardo.at/timetracking/internal/domain"
+	"downardo.at/timetracking/internal/report"
+	"downardo.at/timetracking/internal/utils"
+	"github.com/charmbracelet/huh"
+	"github.com/jedib0t/go-pretty/v6/table"
+)
+
+// parseEstimate parses an estimate like "4h", "1h30m", "2:30" or "1.5"
+// (hours). An empty string is no estimate.
+func parseEstimate(str string) (time.Duration, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return 0, nil
+	}
+	if hours, minutes, ok := strings.Cut(str, ":"); ok {
+		h, err := strconv.Atoi(hours)
+		if err != nil {
+			return 0, err
+		}
+		m, err := strconv.Atoi(minutes)
+		if err != nil || m >= 60 {
+			return 0, errors.New("invalid minutes")
+		}
+		return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute, nil
+	}
+	if hours, err := strconv.ParseFloat(strings.Replace(str, ",", ".", 1), 64); err == nil {
+		return time.Duration(hours * float64(time.Hour)).Round(time.Minute), nil
+	}
+	d, err := time.ParseDuration(str)
+	return d.Round(time.Minute), err
+}
+
+func validateEstimate(str string) error {
+	if d, err := parseEstimate(str); err != nil || d < 0 {
+		return errors.New("please enter an estimate like 4h, 1h30m or 2:30")
+	}
+	return nil
+}
+
+// estimateString formats an estimate for lists and forms, empty if unset.
+func estimateString(d time.Duration) string {
+	if d == 0 {
+		return ""
+	}
+	return utils.FormatDuration(d)
+}
+
+// signedDuration formats a duration that may be negative, e.g. "-01:30".
+func signedDuration(d time.Duration) string {
+	if d < 0 {
+		return "-" + utils.FormatDuration(-d)
+	}
+	return utils.FormatDuration(d)
+}
+
+func doneString(done bool) string {
+	if done {
+		return "done"
+	}
+	return "open"
+}
+
+// openTasks returns the tasks of a project that are not done yet.
+func openTasks(repo domain.Repository, tag string) ([]domain.Task, error) {
+	tasks, err := repo.GetTasksByProjectTag(tag)
+	if err != nil {
+		return nil, err
+	}
+	var open []domain.Task
+	for _, task := range tasks {
+		if !task.Done {
+			open = append(open, task)
+		}
+	}
+	return open, nil
+}
+
+// taskOptions lists the open tasks of a project for selection, starting
+// with "No task". The current task is listed even if it is done.
+func taskOptions(repo domain.Repository, tag string, current int64) []huh.Option[int64] {
+	tasks, err := repo.GetTasksByProjectTag(tag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options := []huh.Option[int64]{huh.NewOption("No task", int64(0))}
+	for _, task := range tasks {
+		if !task.Done || task.ID == current {
+			options = append(options, huh.NewOption(task.Name, task.ID))
+		}
+	}
+	return options
+}
+
+// selectTaskForm asks for one of the open tasks of a project. It returns nil
+// if the project has no open tasks or none was selected.
+func selectTaskForm(repo domain.Repository, tag string) *domain.Task {
+	tasks, err := openTasks(repo, tag)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+	var id int64
+	err = huh.NewSelect[int64]().
+		Title("Task").
+		Options(taskOptions(repo, tag, 0)...).
+		Value(&id).
+		Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, task := range tasks {
+		if task.ID == id {
+			return &task
+		}
+	}
+	return nil
+}
+
+func renderTasks(tasks []report.TaskTotal, style table.Style) {
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"ID", "Tag", "Name", "Status", "Estimate", "Actual", "Remaining", "Used"})
+	for _, task := range tasks {
+		remaining, used := "", ""
+		if task.Estimate != 0 {
+			remaining = signedDuration(task.Remaining())
+			used = fmt.Sprintf("%.0f%%", task.Progress()*100)
+		}
+		t.AppendRow(table.Row{task.ID, task.ProjectTag, task.Name, doneString(task.Done), estimateString(task.Estimate), utils.FormatDuration(task.Actual), remaining, used})
+	}
+	t.SetStyle(style)
+	t.Render()
+}
+
+// loadTaskTotals sums up the booked time of the tasks of a project, or of
+// all projects if tag is empty. Done tasks are left out unless all is set.
+func loadTaskTotals(repo domain.Repository, tag string, all bool) ([]report.TaskTotal, error) {
+	var tasks []domain.Task
+	var recordings []domain.Recording
+	var err error
+	if tag == "" {
+		tasks, err = repo.AllTasks()
+	} else {
+		tasks, err = repo.GetTasksByProjectTag(tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !all {
+		var open []domain.Task
+		for _, task := range tasks {
+			if !task.Done {
+				open = append(open, task)
+			}
+		}
+		tasks = open
+	}
+	if recordings, err = repo.FindRecordings(domain.RecordingFilter{ProjectTag: tag}); err != nil {
+		return nil, err
+	}
+	return report.SummarizeTasks(tasks, recordings), nil
+}
+
+func printTaskList(repo domain.Repository, onlyOpen bool) {
+	clearTerminal()
+
+	tasks, err := loadTaskTotals(repo, "", !onlyOpen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if onlyOpen {
+		Notice("Task List - Open Tasks")
+	} else {
+		Notice("Task List - All Tasks")
+	}
+	renderTasks(tasks, table.StyleDouble)
+	Info("Available commands: [new, edit (id), done (id), delete (id), all, open, exit] [id]")
+	InputPrint()
+}
+
+// parseTaskID reads the task ID argument of a menu command.
+func parseTaskID(repo domain.Repository, text string) (*domain.Task, error) {
+	args := strings.Split(text, " ")
+	if len(args) < 2 || args[1] == "" {
+		return nil, errors.New("please enter an id")
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return nil, errors.New("id has to be a number")
+	}
+	task, err := repo.GetTask(id)
+	if err != nil {
+		return nil, errors.New("task not found")
+	}
+	return task, nil
+}
+
+func taskMenu(repo domain.Repository) {
+	clearTerminal()
+	onlyOpen := true
+	for {
+		printTaskList(repo, onlyOpen)
+		text, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		// convert CRLF to LF
+		// for Windows
+		text = strings.Replace(text, "\r\n", "", -1)
+		// for Linux
+		text = strings.Replace(text, "\n", "", -1)
+		if text == "new" {
+			clearTerminal()
+			addTaskForm(repo)
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "edit") {
+			task, err := parseTaskID(repo, text)
+			if err != nil {
+				Info(err)
+			} else {
+				clearTerminal()
+				editTaskForm(repo, task)
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "done") {
+			task, err := parseTaskID(repo, text)
+			if err != nil {
+				Info(err)
+				pressEnterToContinue()
+			} else {
+				task.Done = true
+				if _, err := repo.UpdateTask(task.ID, *task); err != nil {
+					log.Fatal(err)
+				}
+			}
+		} else if strings.HasPrefix(text, "delete") {
+			task, err := parseTaskID(repo, text)
+			if err != nil {
+				Info(err)
+			} else {
+				clearTerminal()
+				deleteTaskForm(repo, task)
+			}
+			pressEnterToContinue()
+		} else if strings.HasPrefix(text, "exit") {
+			break
+		} else if text == "all" {
+			onlyOpen = false
+		} else if text == "open" {
+			onlyOpen = true
+		} else {
+			Info("Invalid command")
+			pressEnterToContinue()
+		}
+	}
+}
+
+// validateTaskName checks that a name is set and not used by another task
+// of the project.
+func validateTaskName(repo domain.Repository, tag string, id int64, name string) error {
+	if strings.TrimSpace(name) == "" {
+		return errors.New("please enter a name")
+	}
+	tasks, err := repo.GetTasksByProjectTag(tag)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		if task.Name == name && task.ID != id {
+			return errors.New("task already exists")
+		}
+	}
+	return nil
+}
+
+func addTaskForm(repo domain.Repository) {
+	var (
+		tag      string
+		name     string
+		estimate string
+		confirm  bool
+	)
+	options := projectOptions(repo)
+	if len(options) == 0 {
+		Info("There are no projects to add tasks to, add one first")
+		return
+	}
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewSelect[string]().
+				Title("Project").
+				Options(options...).
+				Value(&tag),
+			huh.NewInput().
+				Title("Name").
+				CharLimit(70).
+				Value(&name).
+				Validate(func(str string) error {
+					return validateTaskName(repo, tag, 0, str)
+				}),
+			huh.NewInput().
+				Title("Estimate (e.g. 4h, 1h30m or 2:30, empty for none)").
+				Value(&estimate).
+				Validate(validateEstimate),
+			huh.NewConfirm().
+				Title("Create new task?").
+				Affirmative("Yes!").
+				Negative("No.").
+				Value(&confirm),
+		),
+	)
+	if err := form.Run(); err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Task creation canceled")
+		return
+	}
+	task := domain.Task{ProjectTag: tag, Name: name}
+	task.Estimate, _ = parseEstimate(estimate)
+	if _, err := repo.CreateTask(task); err != nil {
+		log.Fatal(err)
+	}
+	Info("Task created successfully!")
+}
+
+func editTaskForm(repo domain.Repository, task *domain.Task) {
+	var (
+		name     = task.Name
+		estimate = estimateString(task.Estimate)
+		done     = task.Done
+		confirm  bool
+	)
+	form := huh.NewForm(
+		huh.NewGroup(
+			huh.NewNote().
+				Title(fmt.Sprintf("Edit task #%d (%s)", task.ID, task.ProjectTag)),
+			huh.NewInput().
+				Title("Name").
+				CharLimit(70).
+				Value(&name).
+				Validate(func(str string) error {
+					return validateTaskName(repo, task.ProjectTag, task.ID, str)
+				}),
+			huh.NewInput().
+				Title("Estimate (e.g. 4h, 1h30m or 2:30, empty for none)").
+				Value(&estimate).
+				Validate(validateEstimate),
+			huh.NewConfirm().
+				Title("Done?").
+				Value(&done),
+			huh.NewConfirm().
+				Title("Save task?").
+				Affirmative("Yes!").
+				Negative("No.").
+				Value(&confirm),
+		),
+	)
+	if err := form.Run(); err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Task update canceled")
+		return
+	}
+	updated := *task
+	updated.Name = name
+	updated.Done = done
+	updated.Estimate, _ = parseEstimate(estimate)
+	if _, err := repo.UpdateTask(task.ID, updated); err != nil {
+		log.Fatal(err)
+	}
+	Info("Task updated successfully!")
+}
+
+func deleteTaskForm(repo domain.Repository, task *domain.Task) {
+	var confirm bool
+	err := huh.NewConfirm().
+		Title(fmt.Sprintf("Delete task #%d (%s - %s)?", task.ID, task.ProjectTag, task.Name)).
+		Affirmative("Yes!").
+		Negative("No.").
+		Value(&confirm).
+		Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if !confirm {
+		Info("Task deletion canceled")
+		return
+	}
+	if err := repo.DeleteTask(task.ID); errors.Is(err, domain.ErrTaskInUse) {
+		Info("The task has recordings, mark it as done instead")
+		return
+	} else if err != nil {
+		log.Fatal(err)
+	}
+	Info("Task deleted successfully!")
+}
+
+func cmdTask(repo domain.Repository, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: missing task command", errUsage)
+	}
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("task add", flag.ContinueOnError)
+		tag := fs.String("project", "", "project tag")
+		name := fs.String("name", "", "task name")
+		estimate := fs.String("estimate", "", "estimated effort, e.g. 4h, 1h30m or 2:30")
+		rest, err := parseArgs(fs, args[1:])
+		if err != nil {
+			return err
+		}
+		if len(rest) > 0 || *tag == "" || *name == "" {
+			return fmt.Errorf("%w: --project and --name are required", errUsage)
+		}
+		if _, err := repo.GetProjectByTag(*tag); errors.Is(err, domain.ErrNotExists) {
+			return fmt.Errorf("project %q not found", *tag)
+		} else if err != nil {
+			return err
+		}
+		if err := validateTaskName(repo, *tag, 0, *name); err != nil {
+			return err
+		}
+		task := domain.Task{ProjectTag: *tag, Name: *name}
+		if task.Estimate, err = parseEstimate(*estimate); err != nil || task.Estimate < 0 {
+			return fmt.Errorf("%w: invalid --estimate %q", errUsage, *estimate)
+		}
+		created, err := repo.CreateTask(task)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Created task %d\n", created.ID)
+	case "list":
+		fs := flag.NewFlagSet("task list", flag.ContinueOnError)
+		tag := fs.String("project", "", "only tasks of this project")
+		all := fs.Bool("all", false, "include done tasks")
+		if rest, err := parseArgs(fs, args[1:]); err != nil {
+			return err
+		} else if len(rest) > 0 {
+			return fmt.Errorf("%w: unexpected argument %q", errUsage, rest[0])
+		}
+		tasks, err := loadTaskTotals(repo, *tag, *all)
+		if err != nil {
+			return err
+		}
+		renderTasks(tasks, table.StyleLight)
+	case "done", "delete":
+		if len(args) != 2 {
+			return fmt.Errorf("%w: task id is required", errUsage)
+		}
+		id, err := strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: invalid task id %q", errUsage, args[1])
+		}
+		task, err := repo.GetTask(id)
+		if errors.Is(err, domain.ErrNotExists) {
+			return fmt.Errorf("task %d not found", id)
+		} else if err != nil {
+			return err
+		}
+		if args[0] == "done" {
+			task.Done = true
+			if _, err := repo.UpdateTask(id, *task); err != nil {
+				return err
+			}
+			fmt.Printf("Marked task %d as done\n", id)
+			return nil
+		}
+		if err := repo.DeleteTask(id); errors.Is(err, domain.ErrTaskInUse) {
+			return fmt.Errorf("task %d has recordings, mark it as done instead", id)
+		} else if err != nil {
+			return err
+		}
+		fmt.Printf("Deleted task %d\n", id)
+	default:
+		return fmt.Errorf("%w: unknown task command %q", errUsage, args[0])
+	}
+	return nil
+}
+
+// taskReport compares the time booked on tasks with their estimates.
+func taskReport(repo domain.Repository, args []string) error {
+	fs := flag.NewFlagSet("report tasks", flag.ContinueOnError)
+	tag := fs.String("project", "", "only tasks of this project")
+	all := fs.Bool("all", false, "include done tasks")
+	format := fs.String("format", "table", "output format: table, json or csv")
+	if rest, err := parseArgs(fs, args); err != nil {
+		return err
+	} else if len(rest) > 0 {
+		return fmt.Errorf("%w: unexpected argument %q", errUsage, rest[0])
+	}
+	tasks, err := loadTaskTotals(repo, *tag, *all)
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "table":
+		renderTasks(tasks, table.StyleLight)
+	case "json":
+		type taskTotal struct {
+			ID             int64   `json:"id"`
+			Tag            string  `json:"tag"`
+			Name           string  `json:"name"`
+			Done           bool    `json:"done"`
+			EstimatedHours float64 `json:"estimatedHours"`
+			ActualHours    float64 `json:"actualHours"`
+		}
+		data := []taskTotal{}
+		for _, task := range tasks {
+			data = append(data, taskTotal{task.ID, task.ProjectTag, task.Name, task.Done, task.Estimate.Hours(), task.Actual.Hours()})
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"id", "tag", "name", "done", "estimated_hours", "actual_hours"})
+		for _, task := range tasks {
+			w.Write([]string{strconv.FormatInt(task.ID, 10), task.ProjectTag, task.Name, strconv.FormatBool(task.Done), hoursString(task.Estimate), hoursString(task.Actual)})
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		return fmt.Errorf("%w: unknown format %q", errUsage, *format)
+	}
+	return nil
+}